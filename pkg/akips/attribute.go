@@ -0,0 +1,208 @@
+package akips
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AttributeType is the format of an attribute value
+type AttributeType string
+
+// Attribute types known to the decoder
+const (
+	TypeCounter   AttributeType = "counter"
+	TypeGauge     AttributeType = "gauge"
+	TypeEnum      AttributeType = "enum"
+	TypeText      AttributeType = "text"
+	TypeTimestamp AttributeType = "timestamp"
+	TypeIPAddr    AttributeType = "ipaddr"
+)
+
+// ParseAttributeType returns the attribute type or an empty string if s isn't a known type
+func ParseAttributeType(s string) AttributeType {
+	switch t := AttributeType(strings.ToLower(s)); t {
+	case TypeCounter, TypeGauge, TypeEnum, TypeText, TypeTimestamp, TypeIPAddr:
+		return t
+	}
+	return ""
+}
+
+// Positions of the enum tuple members
+const (
+	enumState = iota
+	enumCode
+	enumCreated
+	enumModified
+	enumDescription
+	enumFields
+)
+
+// AttributeValue is a decoded attribute value. Only the members relevant to the type are set
+type AttributeValue struct {
+	Type AttributeType
+	// Raw is the first value as returned by AKiPS
+	Raw string
+	// Number is the value of counters and gauges or the code of an enum state
+	Number float64
+	// Counter is the exact value of counters which may exceed the float64 precision
+	Counter uint64
+	// Text is the enum state or the value of text attributes
+	Text        string
+	Time        time.Time
+	IP          net.IP
+	Created     time.Time
+	Modified    time.Time
+	Description string
+	// Null is set if the attribute has no value
+	Null bool
+	// Invalid is set along with Null if the values don't match the type. Raw holds all the values
+	Invalid bool
+}
+
+func parseUnixTime(s string) (time.Time, error) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(v, 0).UTC(), nil
+}
+
+// isEnum checks if values look like a `state,code,created,modified[,description]` tuple
+func isEnum(values []string) bool {
+	if len(values) < enumDescription {
+		return false
+	}
+	for _, v := range values[enumCode:enumDescription] {
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// DecodeValue decodes the values of an attribute of the given type. If typ is empty the type is detected
+// from the values. Counters can't be told from gauges this way so numbers are reported as gauges,
+// and timestamps are reported as numbers
+func DecodeValue(typ AttributeType, values []string) (v AttributeValue, err error) {
+	if len(values) == 0 || len(values) == 1 && values[0] == "" {
+		return AttributeValue{Type: typ, Null: true}, nil
+	}
+	v.Raw = values[0]
+
+	if typ == "" {
+		switch {
+		case isEnum(values):
+			typ = TypeEnum
+		case len(values) > 1:
+			typ = TypeText
+		case net.ParseIP(v.Raw) != nil:
+			typ = TypeIPAddr
+		default:
+			if _, err := strconv.ParseFloat(v.Raw, 64); err == nil {
+				typ = TypeGauge
+			} else {
+				typ = TypeText
+			}
+		}
+	}
+	v.Type = typ
+
+	switch typ {
+	case TypeCounter:
+		if v.Counter, err = strconv.ParseUint(v.Raw, 10, 64); err != nil {
+			return v, err
+		}
+		v.Number = float64(v.Counter)
+
+	case TypeGauge:
+		if v.Number, err = strconv.ParseFloat(v.Raw, 64); err != nil {
+			return v, err
+		}
+
+	case TypeEnum:
+		if !isEnum(values) {
+			return v, ErrFields
+		}
+		v.Text = v.Raw
+		v.Number, _ = strconv.ParseFloat(values[enumCode], 64)
+		v.Created, _ = parseUnixTime(values[enumCreated])
+		v.Modified, _ = parseUnixTime(values[enumModified])
+		if len(values) > enumDescription {
+			// The description may contain commas
+			v.Description = strings.Join(values[enumDescription:], ",")
+		}
+
+	case TypeTimestamp:
+		if v.Time, err = parseUnixTime(v.Raw); err != nil {
+			t, e := time.Parse(timestampLayout, v.Raw)
+			if e != nil {
+				return v, err
+			}
+			v.Time, err = t.UTC(), nil
+		}
+
+	case TypeIPAddr:
+		if v.IP = net.ParseIP(v.Raw); v.IP == nil {
+			return v, fmt.Errorf("invalid IP address: %q", v.Raw)
+		}
+
+	default:
+		v.Type = TypeText
+		v.Text = strings.Join(values, ",")
+	}
+
+	return v, nil
+}
+
+// AttributeDecoder reads `mget` output one line at a time decoding the values
+type AttributeDecoder struct {
+	dec   *GenericDecoder
+	typ   AttributeType
+	value AttributeValue
+	err   error
+}
+
+// NewAttributeDecoder returns a decoder reading from rd. If typ is empty the type of each value is detected
+func NewAttributeDecoder(rd io.Reader, typ AttributeType) *AttributeDecoder {
+	return &AttributeDecoder{
+		dec: NewGenericDecoder(rd),
+		typ: typ,
+	}
+}
+
+// Next decodes the next entry. It returns false at the end of the response or on error
+func (d *AttributeDecoder) Next() bool {
+	if d.err != nil || !d.dec.Next() {
+		return false
+	}
+	values := d.dec.Entry().Values
+	v, err := DecodeValue(d.typ, values)
+	if err != nil {
+		// A single malformed value doesn't fail the whole response
+		v = AttributeValue{Type: v.Type, Raw: strings.Join(values, ","), Null: true, Invalid: true}
+	}
+	d.value = v
+	return true
+}
+
+// Entry returns the current entry. It's only valid until the next call to Next
+func (d *AttributeDecoder) Entry() *GenericResponseEntry {
+	return d.dec.Entry()
+}
+
+// Value returns the decoded value of the current entry
+func (d *AttributeDecoder) Value() *AttributeValue {
+	return &d.value
+}
+
+// Err returns the first error encountered
+func (d *AttributeDecoder) Err() error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.dec.Err()
+}
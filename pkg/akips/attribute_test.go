@@ -0,0 +1,60 @@
+package akips
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeCounter(t *testing.T) {
+	// Above 2^53 float64 loses precision
+	v, err := DecodeValue(TypeCounter, []string{"9007199254740993"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Counter != 9007199254740993 {
+		t.Errorf("counter = %d", v.Counter)
+	}
+
+	if _, err := DecodeValue(TypeCounter, []string{"-1"}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestDecodeEnum(t *testing.T) {
+	v, err := DecodeValue("", []string{"up", "1", "1600000000", "1600000100", "uplink", " to core"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Type != TypeEnum || v.Text != "up" || v.Number != 1 || v.Modified.Unix() != 1600000100 {
+		t.Errorf("unexpected value %+v", v)
+	}
+	if v.Description != "uplink, to core" {
+		t.Errorf("description = %q", v.Description)
+	}
+}
+
+func TestAttributeDecoderInvalid(t *testing.T) {
+	const input = `sw1 Gi0/1 IF-MIB.ifHCInOctets = 18446744073709551615
+sw1 Gi0/2 IF-MIB.ifHCInOctets = n/a
+sw1 Gi0/3 IF-MIB.ifHCInOctets = 3
+`
+	d := NewAttributeDecoder(strings.NewReader(input), TypeCounter)
+	var got []AttributeValue
+	for d.Next() {
+		got = append(got, *d.Value())
+	}
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d values", len(got))
+	}
+
+	if got[0].Counter != 18446744073709551615 || got[2].Counter != 3 {
+		t.Errorf("unexpected counters %d %d", got[0].Counter, got[2].Counter)
+	}
+	// The malformed value is null rather than failing the response
+	if v := got[1]; !v.Null || !v.Invalid || v.Raw != "n/a" {
+		t.Errorf("unexpected value %+v", v)
+	}
+}
@@ -1,15 +1,33 @@
 package akips
 
-import "net/http"
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+)
 
 // AuthMethod represents an authentication method
 type AuthMethod interface {
 	AuthenticateRequest(r *http.Request)
 }
 
+// FormAuthMethod is implemented by authentication methods which are able to put
+// credentials into a form encoded request body instead of the URL
+type FormAuthMethod interface {
+	AuthenticateForm(values url.Values)
+}
+
 // PasswordAuth authorizes and authenticates the request with a given password
 type PasswordAuth string
 
+// Secrets returns the password
+func (p PasswordAuth) Secrets() []string {
+	return []string{string(p)}
+}
+
 // AuthenticateRequest add a password to request's URL
 func (p PasswordAuth) AuthenticateRequest(r *http.Request) {
 	if p == "" {
@@ -20,6 +38,103 @@ func (p PasswordAuth) AuthenticateRequest(r *http.Request) {
 	r.URL.RawQuery = q.Encode()
 }
 
+// AuthenticateForm adds a password to the form values
+func (p PasswordAuth) AuthenticateForm(values url.Values) {
+	if p == "" {
+		return
+	}
+	values.Set("password", string(p))
+}
+
+// BasicAuth authenticates the request using HTTP Basic authentication
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Secrets returns the password
+func (b *BasicAuth) Secrets() []string {
+	return []string{b.Password}
+}
+
+// AuthenticateRequest sets the Authorization header
+func (b *BasicAuth) AuthenticateRequest(r *http.Request) {
+	r.SetBasicAuth(b.Username, b.Password)
+}
+
+// BearerAuth authenticates the request with a bearer token
+type BearerAuth string
+
+// Secrets returns the token
+func (b BearerAuth) Secrets() []string {
+	return []string{string(b)}
+}
+
+// AuthenticateRequest sets the Authorization header
+func (b BearerAuth) AuthenticateRequest(r *http.Request) {
+	if b == "" {
+		return
+	}
+	r.Header.Set("Authorization", "Bearer "+string(b))
+}
+
+// HeaderAuth authenticates the request with a custom header
+type HeaderAuth struct {
+	Name  string
+	Value string
+}
+
+// Secrets returns the header value
+func (h *HeaderAuth) Secrets() []string {
+	return []string{h.Value}
+}
+
+// AuthenticateRequest sets the header
+func (h *HeaderAuth) AuthenticateRequest(r *http.Request) {
+	if h.Name == "" {
+		return
+	}
+	r.Header.Set(h.Name, h.Value)
+}
+
+// ChainAuth applies several authentication methods in order, e.g. a reverse proxy
+// credentials followed by the AKiPS password
+type ChainAuth []AuthMethod
+
+// Secrets returns secrets of all chained methods
+func (c ChainAuth) Secrets() []string {
+	var s []string
+	for _, a := range c {
+		s = append(s, authSecrets(a)...)
+	}
+	return s
+}
+
+// AuthenticateRequest applies all chained methods to the request's URL and headers
+func (c ChainAuth) AuthenticateRequest(r *http.Request) {
+	for _, a := range c {
+		a.AuthenticateRequest(r)
+	}
+}
+
+// authenticate applies the method preferring the form encoded body when possible
+func authenticate(r *http.Request, a AuthMethod) error {
+	if c, ok := a.(ChainAuth); ok {
+		for _, a := range c {
+			if err := authenticate(r, a); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if fa, ok := a.(FormAuthMethod); ok && isForm(r) {
+		return authenticateForm(r, fa)
+	}
+	a.AuthenticateRequest(r)
+	return nil
+}
+
 // Transport is an http.RoundTripper that makes authenticated AKiPS API requests
 type Transport struct {
 	// Method adds credentials to outgoing requests
@@ -44,11 +159,55 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	for k, s := range req.Header {
 		req2.Header[k] = append([]string(nil), s...)
 	}
+	// Don't leak credentials into the caller's URL which ends up in *url.Error
+	u := *req.URL
+	req2.URL = &u
+
+	if t.AuthMethod == nil {
+		return t.base().RoundTrip(&req2)
+	}
+
+	if err := authenticate(&req2, t.AuthMethod); err != nil {
+		return nil, err
+	}
+
+	res, err := t.base().RoundTrip(&req2)
+	if err != nil {
+		return nil, RedactError(err, authSecrets(t.AuthMethod)...)
+	}
+	return res, nil
+}
+
+func isForm(r *http.Request) bool {
+	if r.Body == nil || r.Method != "POST" && r.Method != "PUT" {
+		return false
+	}
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return ct == "application/x-www-form-urlencoded"
+}
 
-	if t.AuthMethod != nil {
-		t.AuthMethod.AuthenticateRequest(&req2)
+// authenticateForm replaces the request body with the authenticated one
+func authenticateForm(r *http.Request, a FormAuthMethod) error {
+	data, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
 	}
-	return t.base().RoundTrip(&req2)
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	a.AuthenticateForm(values)
+
+	body := []byte(values.Encode())
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.ContentLength = int64(len(body))
+
+	return nil
 }
 
 var _ http.RoundTripper = &Transport{}
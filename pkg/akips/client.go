@@ -0,0 +1,122 @@
+package akips
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// APIPath is the AKiPS database API endpoint
+const APIPath = "/api-db"
+
+// Client executes AKiPS API commands
+type Client struct {
+	config *Config
+	client *http.Client
+}
+
+// NewClient returns a new client using the configuration
+func NewClient(c *Config) *Client {
+	return &Client{
+		config: c,
+		client: c.Client(),
+	}
+}
+
+// Config returns the client configuration
+func (c *Client) Config() *Config {
+	return c.config
+}
+
+func (c *Client) method() string {
+	if c.config.Method != "" {
+		return c.config.Method
+	}
+	return "POST"
+}
+
+// Open executes the command and returns the response body. The caller must close it.
+// The parsers reading from the body respect Config.MaxLineSize
+func (c *Client) Open(ctx context.Context, cmd string) (io.ReadCloser, error) {
+	req, err := c.config.NewRequest(ctx, c.method(), APIPath, url.Values{"cmds": []string{cmd}})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, c.config.RedactError(err)
+	}
+
+	if err := CheckResponse(res); err != nil {
+		res.Body.Close()
+		return nil, c.config.RedactError(err)
+	}
+
+	return &lineLimitReadCloser{ReadCloser: res.Body, max: c.config.MaxLineSize}, nil
+}
+
+// Exec executes the command and parses the response using the parser
+func (c *Client) Exec(ctx context.Context, cmd string, parser ResponseParser) error {
+	body, err := c.Open(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := parser.ParseResponse(body); err != nil {
+		return c.config.RedactError(WithCommand(err, c.config.Redact(cmd)))
+	}
+	return nil
+}
+
+// Mget executes `mget <args>`
+func (c *Client) Mget(ctx context.Context, args string) (GenericResponse, error) {
+	var res GenericResponse
+	if err := c.Exec(ctx, "mget "+args, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Mlist executes `mlist <args>`
+func (c *Client) Mlist(ctx context.Context, args string) (GenericResponse, error) {
+	var res GenericResponse
+	if err := c.Exec(ctx, "mlist "+args, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Get executes `get <args>`
+func (c *Client) Get(ctx context.Context, args string) (CSVResponse, error) {
+	var res CSVResponse
+	if err := c.Exec(ctx, "get "+args, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Series executes `series <args>`
+func (c *Client) Series(ctx context.Context, args string) (GenericResponse, error) {
+	var res GenericResponse
+	if err := c.Exec(ctx, "series "+args, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Cseries executes `cseries <args>` which returns series in CSV format with a header of timestamps
+func (c *Client) Cseries(ctx context.Context, args string) (*TimeSeriesResponse, error) {
+	var res TimeSeriesResponse
+	if err := c.Exec(ctx, "cseries "+args, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Ping checks the connection and the credentials
+func (c *Client) Ping(ctx context.Context) error {
+	return c.Exec(ctx, "mget device __dummy__", TestResponse{})
+}
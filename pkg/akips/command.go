@@ -0,0 +1,273 @@
+package akips
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command is a structured AKiPS command
+type Command interface {
+	Build() (string, error)
+}
+
+// Pattern matches a parent, a child or an attribute name
+type Pattern struct {
+	Value string
+	Regex bool
+}
+
+// Any matches any name
+func Any() Pattern { return Pattern{} }
+
+// Exact matches the name literally
+func Exact(s string) Pattern { return Pattern{Value: s} }
+
+// Regex matches names using a regular expression
+func Regex(s string) Pattern { return Pattern{Value: s, Regex: true} }
+
+// String returns the pattern in the AKiPS syntax
+func (p Pattern) String() string {
+	switch {
+	case p.Value == "" || !p.Regex && p.Value == "*":
+		return "*"
+	case p.Regex:
+		return quoteRegex(p.Value)
+	default:
+		return Quote(p.Value)
+	}
+}
+
+// quoteRegex returns the regular expression delimited by slashes. Slashes which aren't
+// escaped yet are escaped, a trailing backslash is escaped so it doesn't consume the delimiter
+func quoteRegex(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('/')
+	var escaped bool
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == '/':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	if escaped {
+		b.WriteByte('\\')
+	}
+	b.WriteByte('/')
+	return b.String()
+}
+
+// Quote returns a double quoted string escaping quotes and backslashes
+func Quote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Selector selects parents, children and attributes of a given type
+type Selector struct {
+	// Type is the attribute type, e.g. `counter`, `gauge` or `enum`. Any if empty
+	Type      string
+	Parent    Pattern
+	Child     Pattern
+	Attribute Pattern
+}
+
+func isWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Selector) build(b *strings.Builder) error {
+	return s.buildLevel(b, LevelAttribute)
+}
+
+// buildLevel writes the type followed by the patterns down to the given level
+func (s *Selector) buildLevel(b *strings.Builder, level Level) error {
+	typ := s.Type
+	if typ == "" {
+		typ = "*"
+	} else if typ != "*" && !isWord(typ) {
+		return fmt.Errorf("akips: invalid type: %q", typ)
+	}
+	b.WriteString(typ)
+	for i, p := range []Pattern{s.Parent, s.Child, s.Attribute} {
+		if Level(i) > level {
+			break
+		}
+		b.WriteString(" " + p.String())
+	}
+	return nil
+}
+
+// Level limits the depth of the `mlist` output
+type Level int
+
+// Levels of the parent/child/attribute hierarchy
+const (
+	LevelParent Level = iota
+	LevelChild
+	LevelAttribute
+)
+
+// Aggregations supported by the `series` command
+const (
+	AggregateAverage = "avg"
+	AggregateTotal   = "total"
+	AggregateMin     = "min"
+	AggregateMax     = "max"
+)
+
+// TimeWindow is either an absolute time range or an AKiPS time specification such as `last1h`
+type TimeWindow struct {
+	From time.Time
+	To   time.Time
+	Spec string
+}
+
+func (t *TimeWindow) build(b *strings.Builder) error {
+	switch {
+	case t.Spec != "":
+		b.WriteString("time " + Quote(t.Spec))
+	case !t.From.IsZero() && !t.To.IsZero():
+		if t.To.Before(t.From) {
+			return errors.New("akips: time range end is before its start")
+		}
+		fmt.Fprintf(b, `time "from %d to %d"`, t.From.Unix(), t.To.Unix())
+	default:
+		return errors.New("akips: time window is required")
+	}
+	return nil
+}
+
+// SeriesCommand builds `series` and `cseries` commands
+type SeriesCommand struct {
+	// Aggregation is one of avg, total, min or max
+	Aggregation string
+	// Interval is the bucket size in seconds. Must be a multiple of 60
+	Interval int64
+	Time     TimeWindow
+	Selector Selector
+	// CSV selects `cseries` which returns a header of timestamps
+	CSV bool
+}
+
+// Build returns the command string
+func (c *SeriesCommand) Build() (string, error) {
+	var b strings.Builder
+	if c.CSV {
+		b.WriteString("cseries ")
+	} else {
+		b.WriteString("series ")
+	}
+
+	switch c.Aggregation {
+	case AggregateAverage, AggregateTotal, AggregateMin, AggregateMax:
+	default:
+		return "", fmt.Errorf("akips: invalid aggregation: %q", c.Aggregation)
+	}
+	if c.Interval <= 0 || c.Interval%60 != 0 {
+		return "", fmt.Errorf("akips: interval must be a positive multiple of 60 seconds: %d", c.Interval)
+	}
+	b.WriteString("interval " + c.Aggregation + " " + strconv.FormatInt(c.Interval, 10) + " ")
+
+	if err := c.Time.build(&b); err != nil {
+		return "", err
+	}
+	b.WriteByte(' ')
+
+	if err := c.Selector.build(&b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// MgetCommand builds `mget` commands
+type MgetCommand struct {
+	Selector Selector
+}
+
+// Build returns the command string
+func (c *MgetCommand) Build() (string, error) {
+	var b strings.Builder
+	b.WriteString("mget ")
+	if err := c.Selector.build(&b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// MlistCommand builds `mlist` commands
+type MlistCommand struct {
+	Selector Selector
+	// Level is the deepest level to list. The zero value lists parents only
+	Level Level
+}
+
+// Build returns the command string
+func (c *MlistCommand) Build() (string, error) {
+	var b strings.Builder
+	b.WriteString("mlist ")
+	if err := c.Selector.buildLevel(&b, c.Level); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// GetCommand builds `get` commands for a single parent, child and attribute
+type GetCommand struct {
+	Parent    string
+	Child     string
+	Attribute string
+}
+
+// Build returns the command string
+func (c *GetCommand) Build() (string, error) {
+	if c.Parent == "" {
+		return "", errors.New("akips: parent is required")
+	}
+	if c.Child == "" && c.Attribute != "" {
+		return "", errors.New("akips: attribute requires a child")
+	}
+	parts := []string{"get", Quote(c.Parent)}
+	if c.Child != "" {
+		parts = append(parts, Quote(c.Child))
+		if c.Attribute != "" {
+			parts = append(parts, Quote(c.Attribute))
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// ExecCommand builds and executes the command
+func (c *Client) ExecCommand(ctx context.Context, cmd Command, parser ResponseParser) error {
+	s, err := cmd.Build()
+	if err != nil {
+		return err
+	}
+	return c.Exec(ctx, s, parser)
+}
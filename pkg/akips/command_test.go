@@ -0,0 +1,147 @@
+package akips
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuote(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{``, `""`},
+		{`sw1`, `"sw1"`},
+		{`core sw1`, `"core sw1"`},
+		{`sw"1`, `"sw\"1"`},
+		{`sw\1`, `"sw\\1"`},
+		{`\"`, `"\\\""`},
+		{`a,b`, `"a,b"`},
+	} {
+		if got := Quote(tc.in); got != tc.want {
+			t.Errorf("Quote(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	for _, tc := range []struct {
+		p    Pattern
+		want string
+	}{
+		{Any(), `*`},
+		{Exact("*"), `*`},
+		{Exact("sw 1"), `"sw 1"`},
+		{Exact(`sw"1\`), `"sw\"1\\"`},
+		{Regex("*"), `/*/`},
+		{Regex(`^sw\d+$`), `/^sw\d+$/`},
+		{Regex(`Gi0/1`), `/Gi0\/1/`},
+		// Already escaped slashes are kept
+		{Regex(`Gi0\/1`), `/Gi0\/1/`},
+		// An escaped backslash followed by a slash
+		{Regex(`a\\/b`), `/a\\\/b/`},
+		// A trailing backslash doesn't consume the delimiter
+		{Regex(`a\`), `/a\\/`},
+		{Regex(`a b"c`), `/a b"c/`},
+	} {
+		if got := tc.p.String(); got != tc.want {
+			t.Errorf("%+v: got %s, want %s", tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestSeriesCommand(t *testing.T) {
+	from := time.Unix(1600000000, 0)
+	for _, tc := range []struct {
+		cmd  SeriesCommand
+		want string
+		err  bool
+	}{
+		{
+			cmd: SeriesCommand{
+				Aggregation: AggregateAverage,
+				Interval:    60,
+				Time:        TimeWindow{Spec: "last1h"},
+				Selector:    Selector{Type: "counter", Parent: Exact("core sw1"), Child: Regex("^Gi"), Attribute: Exact(`IF-MIB.ifInOctets`)},
+			},
+			want: `series interval avg 60 time "last1h" counter "core sw1" /^Gi/ "IF-MIB.ifInOctets"`,
+		},
+		{
+			cmd: SeriesCommand{
+				Aggregation: AggregateMax,
+				Interval:    300,
+				Time:        TimeWindow{From: from, To: from.Add(time.Hour)},
+				CSV:         true,
+			},
+			want: `cseries interval max 300 time "from 1600000000 to 1600003600" * * * *`,
+		},
+		{
+			cmd: SeriesCommand{
+				Aggregation: AggregateTotal,
+				Interval:    60,
+				Time:        TimeWindow{Spec: `last1h" * * *`},
+				Selector:    Selector{Parent: Exact(`sw"1`)},
+			},
+			want: `series interval total 60 time "last1h\" * * *" * "sw\"1" * *`,
+		},
+		{cmd: SeriesCommand{Aggregation: "median", Interval: 60, Time: TimeWindow{Spec: "last1h"}}, err: true},
+		{cmd: SeriesCommand{Aggregation: AggregateAverage, Interval: 90, Time: TimeWindow{Spec: "last1h"}}, err: true},
+		{cmd: SeriesCommand{Aggregation: AggregateAverage, Interval: 60}, err: true},
+		{cmd: SeriesCommand{Aggregation: AggregateAverage, Interval: 60, Time: TimeWindow{From: from, To: from.Add(-time.Hour)}}, err: true},
+		{cmd: SeriesCommand{Aggregation: AggregateAverage, Interval: 60, Time: TimeWindow{Spec: "last1h"}, Selector: Selector{Type: "gauge *"}}, err: true},
+	} {
+		got, err := tc.cmd.Build()
+		if tc.err {
+			if err == nil {
+				t.Errorf("%+v: expected an error, got %s", tc.cmd, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("got %s, %v, want %s", got, err, tc.want)
+		}
+	}
+}
+
+func TestMgetMlistCommand(t *testing.T) {
+	sel := Selector{Type: "enum", Parent: Regex(`^sw\/1`), Child: Exact(`Gi0/1 "uplink"`), Attribute: Any()}
+	for _, tc := range []struct {
+		cmd  Command
+		want string
+	}{
+		{&MgetCommand{Selector: sel}, `mget enum /^sw\/1/ "Gi0/1 \"uplink\"" *`},
+		{&MlistCommand{Selector: sel}, `mlist enum /^sw\/1/`},
+		{&MlistCommand{Selector: sel, Level: LevelChild}, `mlist enum /^sw\/1/ "Gi0/1 \"uplink\""`},
+		{&MlistCommand{Selector: sel, Level: LevelAttribute}, `mlist enum /^sw\/1/ "Gi0/1 \"uplink\"" *`},
+		{&MlistCommand{}, `mlist * *`},
+	} {
+		if got, err := tc.cmd.Build(); err != nil || got != tc.want {
+			t.Errorf("got %s, %v, want %s", got, err, tc.want)
+		}
+	}
+}
+
+func TestGetCommand(t *testing.T) {
+	for _, tc := range []struct {
+		cmd  GetCommand
+		want string
+		err  bool
+	}{
+		{cmd: GetCommand{Parent: "sw1"}, want: `get "sw1"`},
+		{cmd: GetCommand{Parent: "sw 1", Child: "sys"}, want: `get "sw 1" "sys"`},
+		{cmd: GetCommand{Parent: `sw"1`, Child: `a\b`, Attribute: "SNMPv2-MIB.sysDescr"}, want: `get "sw\"1" "a\\b" "SNMPv2-MIB.sysDescr"`},
+		{cmd: GetCommand{}, err: true},
+		// The attribute isn't dropped silently
+		{cmd: GetCommand{Parent: "sw1", Attribute: "sysDescr"}, err: true},
+	} {
+		got, err := tc.cmd.Build()
+		if tc.err {
+			if err == nil {
+				t.Errorf("%+v: expected an error, got %s", tc.cmd, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("got %s, %v, want %s", got, err, tc.want)
+		}
+	}
+}
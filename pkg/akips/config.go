@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 )
 
 // Config is the client configuration
@@ -14,18 +15,29 @@ type Config struct {
 	AuthMethod AuthMethod
 	URL        string
 	Transport  http.RoundTripper
+	// Method is the HTTP method used by Client. POST if empty
+	Method string
+	// Timeout limits the time taken by a request including reading the response body. Zero means no timeout
+	Timeout time.Duration
+	// MaxLineSize limits the length of a response line read by the parsers. DefaultMaxLineSize if zero
+	MaxLineSize int
 }
 
 // Client creates an *http.Client
 func (c *Config) Client() *http.Client {
-	if c.AuthMethod == nil {
+	if c.AuthMethod == nil && c.Transport == nil && c.Timeout == 0 {
 		return http.DefaultClient
 	}
-	return &http.Client{
-		Transport: &Transport{
+	var rt http.RoundTripper = c.Transport
+	if c.AuthMethod != nil {
+		rt = &Transport{
 			Base:       c.Transport,
 			AuthMethod: c.AuthMethod,
-		},
+		}
+	}
+	return &http.Client{
+		Transport: rt,
+		Timeout:   c.Timeout,
 	}
 }
 
@@ -0,0 +1,226 @@
+package akips
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenericDecoder reads `parent child attribute = value,...` lines one at a time
+// without materialising the whole response
+type GenericDecoder struct {
+	sc    *lineScanner
+	entry GenericResponseEntry
+	err   error
+	reuse bool
+}
+
+// NewGenericDecoder returns a decoder reading from rd. The entry returned by Entry
+// is only valid until the next call to Next
+func NewGenericDecoder(rd io.Reader) *GenericDecoder {
+	return &GenericDecoder{
+		sc:    newLineScanner(rd),
+		reuse: true,
+	}
+}
+
+// Next decodes the next entry. It returns false at the end of the response or on error
+func (d *GenericDecoder) Next() bool {
+	if d.err != nil || !d.sc.Scan() {
+		return false
+	}
+
+	line := d.sc.Text()
+	if e, ok := isError(line); ok {
+		d.err = &Error{Message: e, Line: d.sc.Line()}
+		return false
+	}
+
+	var values []string
+	if d.reuse {
+		values = d.entry.Values[:0]
+	}
+	d.entry = GenericResponseEntry{}
+
+	kv := strings.SplitN(line, "=", 2)
+	pca := strings.Fields(kv[0])
+	if len(pca) != 0 {
+		d.entry.Parent = pca[0]
+	}
+	if len(pca) > 1 {
+		d.entry.Child = pca[1]
+	}
+	if len(pca) > 2 {
+		d.entry.Attribute = pca[2]
+	}
+	if len(kv) > 1 {
+		if values == nil {
+			values = make([]string, 0)
+		}
+		v, err := appendCSV(values, strings.TrimSpace(kv[1]))
+		if err != nil {
+			d.err = parseError(d.sc.Line(), ErrFields)
+			return false
+		}
+		d.entry.Values = v
+	}
+
+	return true
+}
+
+// Entry returns the current entry
+func (d *GenericDecoder) Entry() *GenericResponseEntry {
+	if d.reuse {
+		return &d.entry
+	}
+	e := d.entry
+	return &e
+}
+
+// Err returns the first error encountered
+func (d *GenericDecoder) Err() error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.sc.Err()
+}
+
+// ParseValues parses series cells as nullable integers, or as floats if any of them has a fractional part,
+// in which case ints is nil. Empty cells are null. Cells which aren't numbers are null as well and returned in invalid
+func ParseValues(values []string) (ints []*int64, floats []*float64, invalid []string) {
+	// nullable, backed by a single array
+	iv := make([]int64, len(values))
+	ints = make([]*int64, len(values))
+	var isFloat bool
+	for i, v := range values {
+		if v == "" {
+			continue
+		}
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			iv[i] = n
+			ints[i] = &iv[i]
+		} else if _, err := strconv.ParseFloat(v, 64); err == nil {
+			isFloat = true
+		} else {
+			invalid = append(invalid, v)
+		}
+	}
+	if !isFloat {
+		return ints, nil, invalid
+	}
+
+	fv := make([]float64, len(values))
+	floats = make([]*float64, len(values))
+	for i, v := range values {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			fv[i] = f
+			floats[i] = &fv[i]
+		}
+	}
+	return nil, floats, invalid
+}
+
+// TimeSeriesDecoder reads the CSV formatted `series` output one line at a time.
+// The header with timestamps is read by the first call to Next
+type TimeSeriesDecoder struct {
+	sc     *lineScanner
+	header []time.Time
+	entry  TimeSeriesResponseEntry
+	rec    []string
+	err    error
+	reuse  bool
+}
+
+// NewTimeSeriesDecoder returns a decoder reading from rd. The entry returned by Entry
+// is only valid until the next call to Next, its values may be kept
+func NewTimeSeriesDecoder(rd io.Reader) *TimeSeriesDecoder {
+	return &TimeSeriesDecoder{
+		sc:    newLineScanner(rd),
+		reuse: true,
+	}
+}
+
+func (d *TimeSeriesDecoder) scan() bool {
+	if d.err != nil || !d.sc.Scan() {
+		return false
+	}
+
+	line := d.sc.Text()
+	if e, ok := isError(line); ok {
+		d.err = &Error{Message: e, Line: d.sc.Line()}
+		return false
+	}
+
+	rec, err := appendCSV(d.rec[:0], line)
+	if err != nil {
+		d.err = parseError(d.sc.Line(), err)
+		return false
+	}
+	if len(rec) < 4 {
+		d.err = parseError(d.sc.Line(), ErrFields)
+		return false
+	}
+	d.rec = rec
+
+	return true
+}
+
+// Next decodes the next data line. It returns false at the end of the response or on error
+func (d *TimeSeriesDecoder) Next() bool {
+	if d.header == nil {
+		// Got header
+		if !d.scan() {
+			return false
+		}
+		d.header = make([]time.Time, len(d.rec)-4)
+		for i, v := range d.rec[4:] {
+			t, err := time.Parse(timestampLayout, v)
+			if err != nil {
+				d.err = parseError(d.sc.Line(), err)
+				return false
+			}
+			d.header[i] = t.UTC()
+		}
+	}
+
+	if !d.scan() {
+		return false
+	}
+
+	// Data line. The values aren't reused so they may be kept by the caller
+	ints, floats, invalid := ParseValues(d.rec[4:])
+	d.entry = TimeSeriesResponseEntry{
+		Parent:           d.rec[0],
+		Child:            d.rec[1],
+		ChildDescription: d.rec[2],
+		Attribute:        d.rec[3],
+		Values:           ints,
+		FloatValues:      floats,
+		Invalid:          invalid,
+	}
+
+	return true
+}
+
+// Timestamp returns timestamps from the header line
+func (d *TimeSeriesDecoder) Timestamp() []time.Time {
+	return d.header
+}
+
+// Entry returns the current entry
+func (d *TimeSeriesDecoder) Entry() *TimeSeriesResponseEntry {
+	if d.reuse {
+		return &d.entry
+	}
+	e := d.entry
+	return &e
+}
+
+// Err returns the first error encountered
+func (d *TimeSeriesDecoder) Err() error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.sc.Err()
+}
@@ -0,0 +1,182 @@
+package akips
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const genericInput = `sw1 Gi0/1 IF-MIB.ifInOctets = 1,2,3
+sw1 Gi0/2 IF-MIB.ifInOctets = 4,,6
+sw2
+`
+
+func TestGenericDecoder(t *testing.T) {
+	d := NewGenericDecoder(strings.NewReader(genericInput))
+	var got []GenericResponseEntry
+	for d.Next() {
+		e := *d.Entry()
+		e.Values = append([]string(nil), e.Values...)
+		got = append(got, e)
+	}
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []GenericResponseEntry{
+		{Parent: "sw1", Child: "Gi0/1", Attribute: "IF-MIB.ifInOctets", Values: []string{"1", "2", "3"}},
+		{Parent: "sw1", Child: "Gi0/2", Attribute: "IF-MIB.ifInOctets", Values: []string{"4", "", "6"}},
+		{Parent: "sw2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestGenericDecoderReuse(t *testing.T) {
+	d := NewGenericDecoder(strings.NewReader(genericInput))
+	if !d.Next() {
+		t.Fatal(d.Err())
+	}
+	first := d.Entry()
+	values := first.Values
+	if !d.Next() {
+		t.Fatal(d.Err())
+	}
+	second := d.Entry()
+
+	// The entry and its values are only valid until the next call to Next
+	if first != second {
+		t.Error("the entry isn't reused")
+	}
+	if &values[0] != &second.Values[0] {
+		t.Error("the values aren't reused")
+	}
+}
+
+func TestGenericResponseNoReuse(t *testing.T) {
+	var res GenericResponse
+	if err := res.ParseResponse(strings.NewReader(genericInput)); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("got %d entries", len(res))
+	}
+	if got := res[0].Values; !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Errorf("the first entry has been overwritten: %q", got)
+	}
+}
+
+func TestGenericDecoderError(t *testing.T) {
+	d := NewGenericDecoder(strings.NewReader("sw1 a b = 1\nERROR: bad command\n"))
+	for d.Next() {
+	}
+	var e *Error
+	if err := d.Err(); !errors.As(err, &e) || e.Message != "bad command" || e.Line != 2 {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+const timeSeriesInput = `Device,Child,Description,Attribute,2020-09-13 12:00,2020-09-13 12:01,2020-09-13 12:02
+sw1,Gi0/1,uplink,IF-MIB.ifInOctets,1,,3.5
+sw1,Gi0/2,,IF-MIB.ifInOctets,4,5,6
+`
+
+func TestTimeSeriesDecoder(t *testing.T) {
+	var res TimeSeriesResponse
+	if err := res.ParseResponse(strings.NewReader(timeSeriesInput)); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Date(2020, 9, 13, 12, 0, 0, 0, time.UTC)
+	wantTS := []time.Time{ts, ts.Add(time.Minute), ts.Add(2 * time.Minute)}
+	if !reflect.DeepEqual(res.Timestamp, wantTS) {
+		t.Errorf("timestamps = %v, want %v", res.Timestamp, wantTS)
+	}
+	if len(res.Entries) != 2 {
+		t.Fatalf("got %d entries", len(res.Entries))
+	}
+
+	e := res.Entries[0]
+	if e.Parent != "sw1" || e.Child != "Gi0/1" || e.ChildDescription != "uplink" || e.Attribute != "IF-MIB.ifInOctets" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	// The line has a fractional value so it's parsed as floats, empty cells are null
+	if e.Values != nil || len(e.FloatValues) != 3 {
+		t.Fatalf("unexpected values %v %v", e.Values, e.FloatValues)
+	}
+	if v := e.FloatValues; *v[0] != 1 || v[1] != nil || *v[2] != 3.5 {
+		t.Errorf("unexpected values %v %v %v", v[0], v[1], v[2])
+	}
+
+	e = res.Entries[1]
+	if e.FloatValues != nil || len(e.Values) != 3 {
+		t.Fatalf("unexpected values %v %v", e.Values, e.FloatValues)
+	}
+	if v := e.Values; *v[0] != 4 || *v[1] != 5 || *v[2] != 6 {
+		t.Errorf("unexpected values %v %v %v", *v[0], *v[1], *v[2])
+	}
+	if e.Invalid != nil {
+		t.Errorf("unexpected invalid values %q", e.Invalid)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	ints, floats, invalid := ParseValues([]string{"1", "", "x", "9007199254740993"})
+	if floats != nil || len(ints) != 4 {
+		t.Fatalf("unexpected values %v %v", ints, floats)
+	}
+	// Large integers don't lose precision
+	if *ints[0] != 1 || ints[1] != nil || ints[2] != nil || *ints[3] != 9007199254740993 {
+		t.Errorf("unexpected values %v", ints)
+	}
+	if !reflect.DeepEqual(invalid, []string{"x"}) {
+		t.Errorf("invalid = %q", invalid)
+	}
+}
+
+func genericBenchInput(lines, values int) string {
+	var b strings.Builder
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&b, "sw%d Gi0/%d IF-MIB.ifInOctets = ", i/48, i%48)
+		for j := 0; j < values; j++ {
+			if j != 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%d", i*j)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func BenchmarkGenericResponse(b *testing.B) {
+	input := genericBenchInput(1000, 1440)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var res GenericResponse
+		if err := res.ParseResponse(strings.NewReader(input)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenericDecoder(b *testing.B) {
+	input := genericBenchInput(1000, 1440)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := NewGenericDecoder(strings.NewReader(input))
+		for d.Next() {
+		}
+		if err := d.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
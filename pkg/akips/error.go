@@ -0,0 +1,99 @@
+package akips
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody limits the amount of data read from an unsuccessful response
+const maxErrorBody = 64 * 1024
+
+// ErrParse is matched by all response format errors using errors.Is
+var ErrParse = errors.New("akips: malformed response")
+
+// ErrConcat is returned when time series can't be concatenated
+var ErrConcat = errors.New("akips: series can't be concatenated")
+
+// Error is an error reported by AKiPS itself, i.e. an `ERROR:` line in the response
+type Error struct {
+	// Message is the error text without the `ERROR:` prefix
+	Message string
+	// Line is the response line number starting from 1, if known
+	Line int
+	// Command is the command which caused the error, if known
+	Command string
+}
+
+func (e *Error) Error() string {
+	if e.Command != "" {
+		return fmt.Sprintf("akips: %s (command: %s)", e.Message, e.Command)
+	}
+	return "akips: " + e.Message
+}
+
+// WithCommand attaches the command to an AKiPS error. Other errors are returned as is
+func WithCommand(err error, cmd string) error {
+	var e *Error
+	if errors.As(err, &e) && e.Command == "" {
+		e.Command = cmd
+	}
+	return err
+}
+
+// ParseError is returned when the response doesn't match the expected format
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("akips: line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// Is makes errors.Is(err, ErrParse) true for any parse error
+func (e *ParseError) Is(target error) bool { return target == ErrParse }
+
+func parseError(line int, err error) error {
+	return &ParseError{Line: line, Err: err}
+}
+
+// StatusError is returned by CheckResponse for non 2xx responses
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Messages contains AKiPS `ERROR:` lines found in the response body, if any
+	Messages []string
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Messages) != 0 {
+		return fmt.Sprintf("akips: %s: %s", e.Status, strings.Join(e.Messages, "; "))
+	}
+	return fmt.Sprintf("akips: %s", e.Status)
+}
+
+// CheckResponse returns an error if the response status code is not 2xx.
+// AKiPS `ERROR:` lines found in the response body are included into the message
+func CheckResponse(r *http.Response) error {
+	if r.StatusCode/100 == 2 {
+		return nil
+	}
+
+	e := StatusError{
+		StatusCode: r.StatusCode,
+		Status:     r.Status,
+	}
+	sc := newLineScanner(io.LimitReader(r.Body, maxErrorBody))
+	for sc.Scan() {
+		if msg, ok := isError(sc.Text()); ok {
+			e.Messages = append(e.Messages, msg)
+		}
+	}
+
+	return &e
+}
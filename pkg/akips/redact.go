@@ -0,0 +1,70 @@
+package akips
+
+import (
+	"net/url"
+	"strings"
+)
+
+const redacted = "xxxxx"
+
+// SecretHolder is implemented by authentication methods which carry secrets
+// that must never appear in errors or logs
+type SecretHolder interface {
+	Secrets() []string
+}
+
+// Redact replaces all occurrences of the secrets in s, both raw and URL encoded
+func Redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		s = strings.Replace(s, secret, redacted, -1)
+		if enc := url.QueryEscape(secret); enc != secret {
+			s = strings.Replace(s, enc, redacted, -1)
+		}
+		if enc := url.PathEscape(secret); enc != secret {
+			s = strings.Replace(s, enc, redacted, -1)
+		}
+	}
+	return s
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (r *redactedError) Error() string { return r.msg }
+
+func (r *redactedError) Unwrap() error { return r.err }
+
+// RedactError returns an error with the secrets stripped from its message.
+// The original error is still available through errors.Unwrap
+func RedactError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if r := Redact(msg, secrets...); r != msg {
+		return &redactedError{msg: r, err: err}
+	}
+	return err
+}
+
+func authSecrets(a AuthMethod) []string {
+	if h, ok := a.(SecretHolder); ok {
+		return h.Secrets()
+	}
+	return nil
+}
+
+// Redact strips the configured credentials from s
+func (c *Config) Redact(s string) string {
+	return Redact(s, authSecrets(c.AuthMethod)...)
+}
+
+// RedactError strips the configured credentials from the error message
+func (c *Config) RedactError(err error) error {
+	return RedactError(err, authSecrets(c.AuthMethod)...)
+}
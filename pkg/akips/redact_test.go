@@ -0,0 +1,142 @@
+package akips
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The secret escapes differently in queries and paths
+const testSecret = "p@ss w/rd&x=1"
+
+func assertNoSecret(t *testing.T, s string, secrets ...string) {
+	t.Helper()
+	for _, secret := range secrets {
+		for _, enc := range []string{secret, url.QueryEscape(secret), url.PathEscape(secret)} {
+			if strings.Contains(s, enc) {
+				t.Errorf("%q contains the secret %q", s, enc)
+			}
+		}
+	}
+}
+
+func TestGETErrorRedacted(t *testing.T) {
+	// Nothing listens on port 1
+	cfg := &Config{
+		URL:        "http://127.0.0.1:1",
+		AuthMethod: PasswordAuth(testSecret),
+		Method:     "GET",
+		Timeout:    5 * time.Second,
+	}
+	_, err := NewClient(cfg).Open(context.Background(), "mget * * *")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assertNoSecret(t, err.Error(), testSecret)
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Fatalf("expected *url.Error, got %T", err)
+	}
+	assertNoSecret(t, urlErr.Error(), testSecret)
+	assertNoSecret(t, urlErr.URL, testSecret)
+}
+
+type errorTransport struct{}
+
+// RoundTrip fails with an error containing the authenticated URL
+func (errorTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("dial %s: connection refused", r.URL)
+}
+
+func TestTransportErrorRedacted(t *testing.T) {
+	tr := &Transport{AuthMethod: PasswordAuth(testSecret), Base: errorTransport{}}
+	req, err := http.NewRequest("GET", "http://akips.example.com/api-db?cmds=x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.RoundTrip(req)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assertNoSecret(t, err.Error(), testSecret)
+	if req.URL.Query().Get("password") != "" {
+		t.Error("the caller's URL has been modified")
+	}
+}
+
+func TestPOSTFormBody(t *testing.T) {
+	var (
+		rawQuery string
+		form     url.Values
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		form = r.PostForm
+	}))
+	defer srv.Close()
+
+	cfg := &Config{URL: srv.URL, AuthMethod: PasswordAuth(testSecret)}
+	body, err := NewClient(cfg).Open(context.Background(), "mget * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	assertNoSecret(t, rawQuery, testSecret)
+	if got := form.Get("password"); got != testSecret {
+		t.Errorf("password = %q, want %q", got, testSecret)
+	}
+	if got := form.Get("cmds"); got != "mget * * *" {
+		t.Errorf("cmds = %q", got)
+	}
+}
+
+func TestConfigRedactError(t *testing.T) {
+	secrets := []string{testSecret, "basic-pass", "bearer-token", "header-value"}
+	cfg := &Config{
+		AuthMethod: ChainAuth{
+			&BasicAuth{Username: "user", Password: secrets[1]},
+			BearerAuth(secrets[2]),
+			&HeaderAuth{Name: "X-Auth", Value: secrets[3]},
+			PasswordAuth(secrets[0]),
+		},
+	}
+
+	orig := fmt.Errorf("failed: %s %s %s %s %s", secrets[0], url.QueryEscape(secrets[0]), url.PathEscape(secrets[0]),
+		strings.Join(secrets[1:3], " "), secrets[3])
+	err := cfg.RedactError(orig)
+	assertNoSecret(t, err.Error(), secrets...)
+	if !errors.Is(err, orig) {
+		t.Error("the original error isn't wrapped")
+	}
+
+	if err := cfg.RedactError(errors.New("nothing to hide")); err.Error() != "nothing to hide" {
+		t.Errorf("unexpected message %q", err)
+	}
+	if cfg.RedactError(nil) != nil {
+		t.Error("nil error expected")
+	}
+}
+
+func TestChainAuthSecrets(t *testing.T) {
+	a := ChainAuth{
+		&BasicAuth{Username: "user", Password: "basic-pass"},
+		ChainAuth{BearerAuth("bearer-token"), &HeaderAuth{Name: "X-Auth", Value: "header-value"}},
+		PasswordAuth("password"),
+	}
+	want := []string{"basic-pass", "bearer-token", "header-value", "password"}
+	if got := a.Secrets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Secrets() = %q, want %q", got, want)
+	}
+}
@@ -1,7 +1,7 @@
 package akips
 
 import (
-	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -44,11 +44,16 @@ type NetflowEntry struct {
 }
 
 var (
-	ErrFields = errors.New("akips: incorrect number of fields")
+	// ErrFields is returned when a line has an unexpected number of fields
+	ErrFields = errors.New("incorrect number of fields")
 )
 
 func splitCSV(s string) ([]string, error) {
-	ret := make([]string, 0)
+	return appendCSV(make([]string, 0), s)
+}
+
+// appendCSV appends CSV tokens to ret allowing the slice to be reused between lines
+func appendCSV(ret []string, s string) ([]string, error) {
 	for i := 0; i < len(s); {
 		if s[i] == '"' {
 			var token strings.Builder
@@ -71,7 +76,7 @@ func splitCSV(s string) ([]string, error) {
 			ret = append(ret, token.String())
 			if i < len(s) {
 				if s[i] != ',' {
-					return ret, fmt.Errorf("akips: unexpected character at position %d: '%c'", i, s[i])
+					return ret, fmt.Errorf("unexpected character at position %d: '%c'", i, s[i])
 				}
 				i++
 				if i == len(s) {
@@ -103,6 +108,12 @@ func isError(s string) (string, bool) {
 	return "", false
 }
 
+// HasError reports whether the response contains an `ERROR:` line. AKiPS reports
+// command errors with a successful HTTP status
+func HasError(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(errPrefx)) || bytes.Contains(data, []byte("\n"+errPrefx))
+}
+
 func (f *NetflowEntry) setField(name, value string) error {
 	switch name {
 	case flowSource:
@@ -163,16 +174,16 @@ func (f *NetflowResponse) ParseResponse(rd io.Reader) error {
 	res := NetflowResponse{}
 	mapping := flowDefaultMapping
 
-	sc := bufio.NewScanner(rd)
+	sc := newLineScanner(rd)
 
 	var gotHeader bool
 	for sc.Scan() {
 		if e, ok := isError(sc.Text()); ok {
-			return fmt.Errorf("akips: %s", e)
+			return &Error{Message: e, Line: sc.Line()}
 		}
 		rec, err := splitCSV(sc.Text())
 		if err != nil {
-			return err
+			return parseError(sc.Line(), err)
 		}
 
 		if len(rec) != 0 && len(rec[0]) != 0 && rec[0][0] == '#' {
@@ -189,7 +200,7 @@ func (f *NetflowResponse) ParseResponse(rd io.Reader) error {
 			}
 			for i := 0; i < l; i++ {
 				if err := e.setField(mapping[i], rec[i]); err != nil {
-					return err
+					return parseError(sc.Line(), err)
 				}
 			}
 			res = append(res, &e)
@@ -222,13 +233,16 @@ func (a *NetflowResponse) Append(b NetflowResponse) {
 		return
 	}
 
-	index := make(map[string]*NetflowEntry, len(*a))
+	type flowKey struct {
+		src, dst, proto string
+	}
+	index := make(map[flowKey]*NetflowEntry, len(*a))
 	for _, entry := range *a {
-		index[entry.Source+entry.Destination+entry.Protocol] = entry
+		index[flowKey{entry.Source, entry.Destination, entry.Protocol}] = entry
 	}
 
 	for _, entry := range b {
-		key := entry.Source + entry.Destination + entry.Protocol
+		key := flowKey{entry.Source, entry.Destination, entry.Protocol}
 
 		if dst, ok := index[key]; ok {
 			appendInt64(&dst.Packets, entry.Packets)
@@ -254,23 +268,23 @@ type MsgResponse []*MsgEntry
 func (m *MsgResponse) ParseResponse(rd io.Reader) error {
 	res := MsgResponse{}
 
-	sc := bufio.NewScanner(rd)
+	sc := newLineScanner(rd)
 
 	for sc.Scan() {
 		if e, ok := isError(sc.Text()); ok {
-			return fmt.Errorf("akips: %s", e)
+			return &Error{Message: e, Line: sc.Line()}
 		}
 		header := strings.Fields(sc.Text())
 		if len(header) < 4 {
-			return ErrFields
+			return parseError(sc.Line(), ErrFields)
 		}
 		ts, err := strconv.ParseInt(header[0], 10, 64)
 		if err != nil {
-			return err
+			return parseError(sc.Line(), err)
 		}
 		ipv, err := strconv.ParseInt(header[2], 10, 32)
 		if err != nil {
-			return err
+			return parseError(sc.Line(), err)
 		}
 		// Message
 		msg := make([]string, 0, 1)
@@ -313,18 +327,18 @@ type NetflowTimeSeriesResponse map[string]*NetflowTimeSeries
 func (t *NetflowTimeSeriesResponse) ParseResponse(rd io.Reader) error {
 	res := make(NetflowTimeSeriesResponse, 4)
 
-	sc := bufio.NewScanner(rd)
+	sc := newLineScanner(rd)
 
 	for sc.Scan() {
 		if e, ok := isError(sc.Text()); ok {
-			return fmt.Errorf("akips: %s", e)
+			return &Error{Message: e, Line: sc.Line()}
 		}
 		rec, err := splitCSV(sc.Text())
 		if err != nil {
-			return err
+			return parseError(sc.Line(), err)
 		}
 		if len(rec) < 8 {
-			return ErrFields
+			return parseError(sc.Line(), ErrFields)
 		}
 
 		ivalues := make([]int64, len(rec)-6)
@@ -333,7 +347,7 @@ func (t *NetflowTimeSeriesResponse) ParseResponse(rd io.Reader) error {
 			if v != "" {
 				iv, err := strconv.ParseInt(v, 10, 64)
 				if err != nil {
-					return err
+					return parseError(sc.Line(), err)
 				}
 				ivalues[i] = iv
 			}
@@ -382,17 +396,73 @@ func (a *NetflowTimeSeriesResponse) Append(b NetflowTimeSeriesResponse) {
 	}
 }
 
+// Concat appends the values of b which follow those of a, e.g. to merge the results of consecutive
+// time ranges. Series with different intervals, or which neither overlap nor adjoin, can't be merged
+// without dropping or inventing values. In that case ErrConcat is returned and a is left unchanged
+func (a *NetflowTimeSeriesResponse) Concat(b NetflowTimeSeriesResponse) error {
+	if *a == nil {
+		*a = b
+		return nil
+	}
+
+	// Position of the first src value in dst
+	pos := make(map[string]int, len(b))
+	for typ, src := range b {
+		dst, ok := (*a)[typ]
+		if !ok {
+			continue
+		}
+		if dst.Interval <= 0 || dst.Interval != src.Interval {
+			return fmt.Errorf("%w: %s: interval %ds differs from %ds", ErrConcat, typ, src.Interval, dst.Interval)
+		}
+		step := time.Duration(dst.Interval) * time.Second
+		offset := src.Start.Sub(dst.Start)
+		p := int(offset / step)
+		if offset < 0 || offset%step != 0 || p > len(dst.Values) {
+			return fmt.Errorf("%w: %s: series starting at %v doesn't follow %v", ErrConcat, typ, src.Start, dst.Start)
+		}
+		pos[typ] = p
+	}
+
+	for typ, src := range b {
+		dst, ok := (*a)[typ]
+		if !ok {
+			(*a)[typ] = src
+			continue
+		}
+		if skip := len(dst.Values) - pos[typ]; skip < len(src.Values) {
+			dst.Values = append(dst.Values, src.Values[skip:]...)
+		}
+	}
+	return nil
+}
+
+// IsTimeSeriesHeader reports whether the line is a header of the CSV formatted `series` output
+func IsTimeSeriesHeader(s string) bool {
+	rec, err := splitCSV(strings.TrimRight(s, "\r"))
+	if err != nil || len(rec) < 5 {
+		return false
+	}
+	_, err = time.Parse(timestampLayout, rec[4])
+	return err == nil
+}
+
 type TimeSeriesResponse struct {
 	Timestamp []time.Time                `json:"ts"`
 	Entries   []*TimeSeriesResponseEntry `json:"entries"`
 }
 
 type TimeSeriesResponseEntry struct {
-	Parent           string  `json:"parent,omitempty"`
-	Child            string  `json:"child,omitempty"`
-	ChildDescription string  `json:"childDesc,omitempty"`
-	Attribute        string  `json:"attr,omitempty"`
-	Values           []int64 `json:"val"`
+	Parent           string `json:"parent,omitempty"`
+	Child            string `json:"child,omitempty"`
+	ChildDescription string `json:"childDesc,omitempty"`
+	Attribute        string `json:"attr,omitempty"`
+	// Values are nil for empty cells. Values is nil if any value has a fractional part
+	Values []*int64 `json:"val"`
+	// FloatValues are set instead of Values if any value has a fractional part
+	FloatValues []*float64 `json:"fval,omitempty"`
+	// Invalid holds the cells which aren't numbers. They are null in the values
+	Invalid []string `json:"invalid,omitempty"`
 }
 
 func (t *TimeSeriesResponse) ParseResponse(rd io.Reader) error {
@@ -400,60 +470,14 @@ func (t *TimeSeriesResponse) ParseResponse(rd io.Reader) error {
 		Entries: make([]*TimeSeriesResponseEntry, 0),
 	}
 
-	sc := bufio.NewScanner(rd)
-
-	for sc.Scan() {
-		if e, ok := isError(sc.Text()); ok {
-			return fmt.Errorf("akips: %s", e)
-		}
-		rec, err := splitCSV(sc.Text())
-		if err != nil {
-			return err
-		}
-		if len(rec) < 4 {
-			return ErrFields
-		}
-
-		if res.Timestamp == nil {
-			// Got header
-			res.Timestamp = make([]time.Time, len(rec)-4)
-			for i, v := range rec[4:] {
-				t, err := time.Parse(timestampLayout, v)
-				if err != nil {
-					return err
-				}
-				res.Timestamp[i] = t.UTC()
-			}
-		} else {
-			// Data line
-			ivalues := make([]int64, len(rec)-4)
-
-			for i, v := range rec[4:] {
-				if v != "" {
-					iv, err := strconv.ParseInt(v, 10, 64)
-					if err != nil {
-						return err
-					}
-
-					ivalues[i] = iv
-				}
-			}
-
-			entry := TimeSeriesResponseEntry{
-				Parent:           rec[0],
-				Child:            rec[1],
-				ChildDescription: rec[2],
-				Attribute:        rec[3],
-				Values:           ivalues,
-			}
-
-			res.Entries = append(res.Entries, &entry)
-		}
+	d := TimeSeriesDecoder{sc: newLineScanner(rd)}
+	for d.Next() {
+		res.Entries = append(res.Entries, d.Entry())
 	}
-
-	if err := sc.Err(); err != nil {
+	if err := d.Err(); err != nil {
 		return err
 	}
+	res.Timestamp = d.Timestamp()
 	*t = res
 
 	return nil
@@ -472,36 +496,11 @@ type GenericResponseEntry struct {
 func (p *GenericResponse) ParseResponse(rd io.Reader) error {
 	res := GenericResponse{}
 
-	sc := bufio.NewScanner(rd)
-
-	for sc.Scan() {
-		if e, ok := isError(sc.Text()); ok {
-			return fmt.Errorf("akips: %s", e)
-		}
-
-		var entry GenericResponseEntry
-		kv := strings.SplitN(sc.Text(), "=", 2)
-		pca := strings.Fields(kv[0])
-		if len(pca) != 0 {
-			entry.Parent = pca[0]
-		}
-		if len(pca) > 1 {
-			entry.Child = pca[1]
-		}
-		if len(pca) > 2 {
-			entry.Attribute = pca[2]
-		}
-		if len(kv) > 1 {
-			v, err := splitCSV(strings.TrimSpace(kv[1]))
-			if err != nil {
-				return ErrFields
-			}
-			entry.Values = v
-		}
-		res = append(res, &entry)
+	d := GenericDecoder{sc: newLineScanner(rd)}
+	for d.Next() {
+		res = append(res, d.Entry())
 	}
-
-	if err := sc.Err(); err != nil {
+	if err := d.Err(); err != nil {
 		return err
 	}
 	*p = res
@@ -514,16 +513,16 @@ type CSVResponse [][]string
 
 func (c *CSVResponse) ParseResponse(rd io.Reader) error {
 	res := [][]string{}
-	sc := bufio.NewScanner(rd)
+	sc := newLineScanner(rd)
 
 	for sc.Scan() {
 		if e, ok := isError(sc.Text()); ok {
-			return fmt.Errorf("akips: %s", e)
+			return &Error{Message: e, Line: sc.Line()}
 		}
 
 		v, err := splitCSV(sc.Text())
 		if err != nil {
-			return ErrFields
+			return parseError(sc.Line(), ErrFields)
 		}
 		res = append(res, v)
 	}
@@ -539,11 +538,11 @@ func (c *CSVResponse) ParseResponse(rd io.Reader) error {
 type TestResponse struct{}
 
 func (t TestResponse) ParseResponse(rd io.Reader) error {
-	sc := bufio.NewScanner(rd)
+	sc := newLineScanner(rd)
 
 	for sc.Scan() {
 		if e, ok := isError(sc.Text()); ok {
-			return fmt.Errorf("akips: %s", e)
+			return &Error{Message: e, Line: sc.Line()}
 		}
 	}
 	if err := sc.Err(); err != nil {
@@ -0,0 +1,17 @@
+package akips
+
+import "testing"
+
+func TestHasError(t *testing.T) {
+	for s, want := range map[string]bool{
+		"ERROR: bad command\n":         true,
+		"sw1 a b = 1\r\nERROR: busy\n": true,
+		"sw1 a b = ERROR: x\n":         false,
+		"sw1 a b = 1\n":                false,
+		"":                             false,
+	} {
+		if got := HasError([]byte(s)); got != want {
+			t.Errorf("HasError(%q) = %v", s, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package akips
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxLineSize is the default limit on the length of a single line of an AKiPS response.
+// Unlike bufio.Scanner the parsers don't impose any smaller limit
+const DefaultMaxLineSize = 64 * 1024 * 1024
+
+// ErrLineTooLong is returned by the parsers when a response line exceeds the limit
+var ErrLineTooLong = errors.New("akips: line too long")
+
+// lineLimiter is implemented by readers carrying their own line length limit
+type lineLimiter interface {
+	maxLineSize() int
+}
+
+type lineLimitReader struct {
+	io.Reader
+	max int
+}
+
+func (r *lineLimitReader) maxLineSize() int { return r.max }
+
+type lineLimitReadCloser struct {
+	io.ReadCloser
+	max int
+}
+
+func (r *lineLimitReadCloser) maxLineSize() int { return r.max }
+
+// WithMaxLineSize returns a reader which makes the parsers and decoders reading from it fail
+// with ErrLineTooLong on lines longer than n bytes. DefaultMaxLineSize is used if n is zero
+func WithMaxLineSize(rd io.Reader, n int) io.Reader {
+	return &lineLimitReader{Reader: rd, max: n}
+}
+
+// lineScanner is a bufio.Scanner-like line reader without the token size limit
+type lineScanner struct {
+	r    *bufio.Reader
+	max  int
+	line []byte
+	num  int
+	err  error
+	eof  bool
+}
+
+func newLineScanner(rd io.Reader) *lineScanner {
+	max := DefaultMaxLineSize
+	if l, ok := rd.(lineLimiter); ok && l.maxLineSize() > 0 {
+		max = l.maxLineSize()
+	}
+	return &lineScanner{
+		r:   bufio.NewReader(rd),
+		max: max,
+	}
+}
+
+// Scan advances to the next line stripping the line terminator
+func (s *lineScanner) Scan() bool {
+	if s.err != nil || s.eof {
+		return false
+	}
+
+	s.line = s.line[:0]
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		s.line = append(s.line, chunk...)
+		// Allow for "\r\n"
+		if len(s.line) > s.max+2 {
+			s.err = fmt.Errorf("%w: line %d exceeds %d bytes", ErrLineTooLong, s.num+1, s.max)
+			return false
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			s.eof = true
+			if len(s.line) == 0 {
+				return false
+			}
+			break
+		}
+		if err != nil {
+			s.err = err
+			return false
+		}
+		break
+	}
+
+	if l := len(s.line); l != 0 && s.line[l-1] == '\n' {
+		s.line = s.line[:l-1]
+	}
+	if l := len(s.line); l != 0 && s.line[l-1] == '\r' {
+		s.line = s.line[:l-1]
+	}
+	if len(s.line) > s.max {
+		s.err = fmt.Errorf("%w: line %d exceeds %d bytes", ErrLineTooLong, s.num+1, s.max)
+		return false
+	}
+
+	s.num++
+	return true
+}
+
+// Text returns the current line
+func (s *lineScanner) Text() string {
+	return string(s.line)
+}
+
+// Line returns the current line number starting from 1
+func (s *lineScanner) Line() int {
+	return s.num
+}
+
+// Err returns the first non-EOF error
+func (s *lineScanner) Err() error {
+	return s.err
+}
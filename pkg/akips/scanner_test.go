@@ -0,0 +1,82 @@
+package akips
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// longLine returns a `parent child attribute = values` line longer than the bufio.Scanner limit
+func longLine(n int) string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = fmt.Sprint(i)
+	}
+	return "sw1 Gi0/1 IF-MIB.ifInOctets = " + strings.Join(values, ",")
+}
+
+func TestLongLine(t *testing.T) {
+	line := longLine(20000)
+	if len(line) <= 64*1024 {
+		t.Fatalf("the line is only %d bytes", len(line))
+	}
+
+	var res GenericResponse
+	if err := res.ParseResponse(strings.NewReader(line + "\r\nsw2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || len(res[0].Values) != 20000 || res[0].Values[19999] != "19999" || res[1].Parent != "sw2" {
+		t.Errorf("unexpected response: %d entries", len(res))
+	}
+}
+
+func TestLineTooLong(t *testing.T) {
+	line := longLine(20000)
+
+	var res GenericResponse
+	err := res.ParseResponse(WithMaxLineSize(strings.NewReader("sw1\n"+line+"\n"), 64*1024))
+	if !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("the line number is missing: %v", err)
+	}
+
+	// The limit applies to the decoders as well
+	d := NewGenericDecoder(WithMaxLineSize(strings.NewReader(line), 1024))
+	for d.Next() {
+	}
+	if err := d.Err(); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// A line of exactly the limit is fine, the terminator isn't counted
+	if err := res.ParseResponse(WithMaxLineSize(strings.NewReader("sw1 a b = 1\r\n"), len("sw1 a b = 1"))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientMaxLineSize(t *testing.T) {
+	line := longLine(20000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, line)
+	}))
+	defer srv.Close()
+
+	// The limits of clients are independent
+	small := NewClient(&Config{URL: srv.URL, MaxLineSize: 1024})
+	if _, err := small.Mget(context.Background(), "* * *"); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("unexpected error %v", err)
+	}
+	res, err := NewClient(&Config{URL: srv.URL}).Mget(context.Background(), "* * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || len(res[0].Values) != 20000 {
+		t.Errorf("unexpected response")
+	}
+}
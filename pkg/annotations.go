@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+type annotationFrameBuilder struct {
+	time    []time.Time
+	timeEnd []time.Time
+	title   []string
+	text    []string
+	tags    []string
+}
+
+func (b *annotationFrameBuilder) add(ts time.Time, title, text string, tags ...string) {
+	t := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag != "" {
+			t = append(t, tag)
+		}
+	}
+
+	b.time = append(b.time, ts)
+	b.timeEnd = append(b.timeEnd, ts)
+	b.title = append(b.title, title)
+	b.text = append(b.text, text)
+	b.tags = append(b.tags, strings.Join(t, ","))
+}
+
+func (b *annotationFrameBuilder) frame() *data.Frame {
+	return data.NewFrame("",
+		data.NewField("time", nil, b.time),
+		data.NewField("timeEnd", nil, b.timeEnd),
+		data.NewField("title", nil, b.title),
+		data.NewField("text", nil, b.text),
+		data.NewField("tags", nil, b.tags),
+	)
+}
+
+// isMessages checks if the line is a `timestamp type ipver address` message header
+func isMessages(line []byte) bool {
+	if bytes.IndexByte(line, '=') >= 0 {
+		return false
+	}
+	f := strings.Fields(string(line))
+	if len(f) < 4 {
+		return false
+	}
+	_, err := strconv.ParseInt(f[0], 10, 64)
+	return err == nil
+}
+
+// processAnnotations produces an annotation frame either from syslog/trap messages
+// or from status changes reported by `mget enum` (the modification time of the state tuple)
+func processAnnotations(rd io.Reader, first []byte, query *query, frameMeta *data.FrameMeta) (res backend.DataResponse, err error) {
+	var b annotationFrameBuilder
+
+	if isMessages(first) {
+		var akipsResponse akips.MsgResponse
+		if err := akipsResponse.ParseResponse(rd); err != nil {
+			return backend.DataResponse{Error: err}, nil
+		}
+
+		for _, e := range akipsResponse {
+			b.add(e.Timestamp, e.Type, strings.Join(e.Msg, "\n"), e.Addr, e.Type)
+		}
+	} else {
+		var akipsResponse akips.GenericResponse
+		if err := akipsResponse.ParseResponse(rd); err != nil {
+			return backend.DataResponse{Error: err}, nil
+		}
+
+		tr := query.query.TimeRange
+		for _, e := range akipsResponse {
+			v, err := akips.DecodeValue(akips.TypeEnum, e.Values)
+			if err != nil || v.Null {
+				continue
+			}
+			ts := v.Modified
+			if ts.Before(tr.From) || ts.After(tr.To) {
+				continue
+			}
+
+			title := fmt.Sprintf("%s: %s", fieldName(e), v.Text)
+			b.add(ts, title, v.Description, e.Parent, e.Child, e.Attribute)
+		}
+	}
+
+	if len(b.time) == 0 {
+		return
+	}
+
+	frame := b.frame()
+	frame.RefID = query.query.RefID
+	frame.Meta = frameMeta
+	res.Frames = data.Frames{frame}
+
+	return
+}
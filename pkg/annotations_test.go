@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestEnumAnnotations(t *testing.T) {
+	const input = `sw1 Gi0/1 IF-MIB.ifOperStatus = up,1,1600000000,1600000100,uplink, to core, port 1
+sw1 Gi0/2 IF-MIB.ifOperStatus = down,2,1600000000,1500000000,
+sw2 Gi0/1 IF-MIB.ifOperStatus = up,1,1600000000,1600000200
+`
+	q := &query{
+		query: &backend.DataQuery{
+			RefID:     "A",
+			QueryType: queryAnnotation,
+			TimeRange: backend.TimeRange{
+				From: time.Unix(1600000000, 0),
+				To:   time.Unix(1600001000, 0),
+			},
+		},
+		model: &queryModel{},
+	}
+	res, err := processSeries(strings.NewReader(input), q, &data.FrameMeta{})
+	if err != nil || res.Error != nil {
+		t.Fatal(err, res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames", len(res.Frames))
+	}
+
+	// The change outside of the time range is skipped
+	f := res.Frames[0]
+	if f.Rows() != 2 {
+		t.Fatalf("got %d annotations", f.Rows())
+	}
+	if ts := f.Fields[0].At(0).(time.Time); !ts.Equal(time.Unix(1600000100, 0)) {
+		t.Errorf("time = %v", ts)
+	}
+	if title := f.Fields[2].At(0).(string); !strings.HasSuffix(title, ": up") {
+		t.Errorf("title = %q", title)
+	}
+	// The description isn't cut at commas
+	if text := f.Fields[3].At(0).(string); text != "uplink, to core, port 1" {
+		t.Errorf("text = %q", text)
+	}
+	if text := f.Fields[3].At(1).(string); text != "" {
+		t.Errorf("text = %q", text)
+	}
+}
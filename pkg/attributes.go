@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+// attributeType returns the type given in a `mget <type> ...` command, if any
+func attributeType(cmd string) akips.AttributeType {
+	f := strings.Fields(cmd)
+	if len(f) < 2 || f[0] != "mget" {
+		return ""
+	}
+	return akips.ParseAttributeType(f[1])
+}
+
+type attributeFrameBuilder struct {
+	parent      []string
+	child       []string
+	attribute   []string
+	typ         []string
+	state       []*string
+	stateCode   []*int64
+	counter     []*uint64
+	value       []*float64
+	text        []*string
+	address     []*string
+	time        []*time.Time
+	created     []*time.Time
+	modified    []*time.Time
+	description []*string
+
+	types   map[akips.AttributeType]struct{}
+	invalid []string
+}
+
+func (b *attributeFrameBuilder) add(e *akips.GenericResponseEntry, value *akips.AttributeValue) {
+	// The decoder reuses the value
+	v := *value
+
+	var (
+		state, text, address, description *string
+		stateCode                         *int64
+		counter                           *uint64
+		number                            *float64
+		ts, created, modified             *time.Time
+	)
+
+	if b.types == nil {
+		b.types = make(map[akips.AttributeType]struct{})
+	}
+	if v.Invalid {
+		b.invalid = append(b.invalid, v.Raw)
+	}
+	if !v.Null {
+		b.types[v.Type] = struct{}{}
+
+		switch v.Type {
+		case akips.TypeCounter:
+			counter = &v.Counter
+		case akips.TypeGauge:
+			number = &v.Number
+		case akips.TypeEnum:
+			code := int64(v.Number)
+			state, stateCode = &v.Text, &code
+			created, modified = &v.Created, &v.Modified
+			if v.Description != "" {
+				description = &v.Description
+			}
+		case akips.TypeTimestamp:
+			ts = &v.Time
+		case akips.TypeIPAddr:
+			s := v.IP.String()
+			address = &s
+		default:
+			text = &v.Text
+		}
+	}
+
+	b.parent = append(b.parent, e.Parent)
+	b.child = append(b.child, e.Child)
+	b.attribute = append(b.attribute, e.Attribute)
+	b.typ = append(b.typ, string(v.Type))
+	b.state = append(b.state, state)
+	b.stateCode = append(b.stateCode, stateCode)
+	b.counter = append(b.counter, counter)
+	b.value = append(b.value, number)
+	b.text = append(b.text, text)
+	b.address = append(b.address, address)
+	b.time = append(b.time, ts)
+	b.created = append(b.created, created)
+	b.modified = append(b.modified, modified)
+	b.description = append(b.description, description)
+}
+
+func (b *attributeFrameBuilder) has(types ...akips.AttributeType) bool {
+	for _, t := range types {
+		if _, ok := b.types[t]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// frame returns the name columns followed by the value columns of the types found in the response
+func (b *attributeFrameBuilder) frame(omitParents bool) *data.Frame {
+	var fields []*data.Field
+	if !omitParents {
+		fields = append(fields,
+			data.NewField("Parent", nil, b.parent),
+			data.NewField("Child", nil, b.child),
+			data.NewField("Attribute", nil, b.attribute),
+		)
+	} else {
+		name := make([]string, len(b.parent))
+		for i := range name {
+			name[i] = fieldName(&akips.GenericResponseEntry{Parent: b.parent[i], Child: b.child[i], Attribute: b.attribute[i]})
+		}
+		fields = append(fields, data.NewField("Name", nil, name))
+	}
+
+	if len(b.types) > 1 {
+		fields = append(fields, data.NewField("Type", nil, b.typ))
+	}
+	if b.has(akips.TypeEnum) {
+		fields = append(fields,
+			data.NewField("State", nil, b.state),
+			data.NewField("StateCode", nil, b.stateCode),
+		)
+	}
+	if b.has(akips.TypeCounter) {
+		fields = append(fields, data.NewField("Counter", nil, b.counter))
+	}
+	if b.has(akips.TypeGauge) {
+		fields = append(fields, data.NewField("Value", nil, b.value))
+	}
+	if b.has(akips.TypeText) {
+		fields = append(fields, data.NewField("Text", nil, b.text))
+	}
+	if b.has(akips.TypeIPAddr) {
+		fields = append(fields, data.NewField("Address", nil, b.address))
+	}
+	if b.has(akips.TypeTimestamp) {
+		fields = append(fields, data.NewField("Time", nil, b.time))
+	}
+	if b.has(akips.TypeEnum) {
+		fields = append(fields,
+			data.NewField("Created", nil, b.created),
+			data.NewField("Modified", nil, b.modified),
+			data.NewField("Description", nil, b.description),
+		)
+	}
+
+	return data.NewFrame("", fields...)
+}
+
+// processAttributes produces a table with named and typed columns from the `mget` output
+func processAttributes(dec *akips.AttributeDecoder, query *query, frameMeta *data.FrameMeta) (res backend.DataResponse, err error) {
+	var b attributeFrameBuilder
+	for dec.Next() {
+		b.add(dec.Entry(), dec.Value())
+	}
+	if err := dec.Err(); err != nil {
+		return backend.DataResponse{Error: err}, nil
+	}
+
+	if len(b.parent) == 0 {
+		return
+	}
+
+	frame := b.frame(query.model.OmitParents)
+	frame.RefID = query.query.RefID
+	frame.Meta = frameMeta
+	if len(b.invalid) != 0 {
+		m := *frameMeta
+		m.Notices = append(append([]data.Notice(nil), m.Notices...), data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("%d value(s) could not be decoded, e.g. %q", len(b.invalid), b.invalid[0]),
+		})
+		frame.Meta = &m
+	}
+	res.Frames = data.Frames{frame}
+
+	return
+}
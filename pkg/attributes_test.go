@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+func TestProcessAttributesCounter(t *testing.T) {
+	const input = `sw1 Gi0/1 IF-MIB.ifHCInOctets = 9007199254740993
+sw1 Gi0/2 IF-MIB.ifHCInOctets = n/a
+`
+	q := &query{query: &backend.DataQuery{RefID: "A"}, model: &queryModel{}}
+	dec := akips.NewAttributeDecoder(strings.NewReader(input), attributeType("mget counter * * *"))
+	res, err := processAttributes(dec, q, &data.FrameMeta{})
+	if err != nil || res.Error != nil {
+		t.Fatal(err, res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames", len(res.Frames))
+	}
+
+	f := res.Frames[0]
+	var counter *data.Field
+	for _, field := range f.Fields {
+		if field.Name == "Counter" {
+			counter = field
+		}
+	}
+	if counter == nil {
+		t.Fatal("no Counter column")
+	}
+	if v := counter.At(0).(*uint64); v == nil || *v != 9007199254740993 {
+		t.Errorf("unexpected value %v", v)
+	}
+	if v := counter.At(1).(*uint64); v != nil {
+		t.Errorf("invalid value = %d, want nil", *v)
+	}
+	if n := len(f.Meta.Notices); n != 1 {
+		t.Errorf("got %d notices", n)
+	}
+}
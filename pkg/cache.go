@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+// responseCache keeps raw AKiPS responses for a limited time and coalesces
+// concurrent requests for the same key into a single call
+type responseCache struct {
+	ttl     time.Duration
+	maxSize int
+	// timeout bounds shared calls which aren't tied to any caller's context
+	timeout time.Duration
+
+	mtx     sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used
+	calls   map[string]*cacheCall
+}
+
+type cacheEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+type cacheCall struct {
+	done     chan struct{}
+	data     []byte
+	err      error
+	tooLarge bool
+	stream   *callStream // the response if it's too large to be cached, until taken by a caller
+	waiters  int
+	cancel   context.CancelFunc
+}
+
+// callStream is a response which is passed through without caching.
+// Closing it releases the call context
+type callStream struct {
+	io.Reader
+	body   io.Closer
+	cancel context.CancelFunc
+	done   <-chan struct{}
+	once   sync.Once
+}
+
+func (s *callStream) Close() (err error) {
+	s.once.Do(func() {
+		err = s.body.Close()
+		s.cancel()
+	})
+	return err
+}
+
+func newResponseCache(ttl time.Duration, maxSize int, timeout time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		timeout: timeout,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		calls:   make(map[string]*cacheCall),
+	}
+}
+
+// get returns a cached response or calls fetch. Callers asking for a key which is already
+// being fetched wait for the result instead of calling fetch again. fetch runs on its own context
+// so a caller giving up doesn't fail the others. It's cancelled once all callers have given up.
+// Responses larger than the cache are streamed to the first caller, the others fetch their own copy
+func (c *responseCache) get(ctx context.Context, key string, fetch func(ctx context.Context) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	c.mtx.Lock()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*cacheEntry)
+		if time.Now().Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.mtx.Unlock()
+			return ioutil.NopCloser(bytes.NewReader(e.data)), nil
+		}
+		c.remove(el)
+	}
+
+	call, ok := c.calls[key]
+	if !ok {
+		call = c.call(key, fetch)
+	}
+	call.waiters++
+	c.mtx.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		c.leave(key, call)
+		return nil, ctx.Err()
+	}
+
+	c.mtx.Lock()
+	call.waiters--
+	stream := call.stream
+	call.stream = nil
+	c.mtx.Unlock()
+
+	switch {
+	case call.err != nil:
+		return nil, call.err
+	case stream != nil:
+		go func() {
+			select {
+			case <-ctx.Done():
+				stream.Close()
+			case <-stream.done:
+			}
+		}()
+		return stream, nil
+	case call.tooLarge:
+		// The response has been taken by another caller
+		return fetch(ctx)
+	}
+	return ioutil.NopCloser(bytes.NewReader(call.data)), nil
+}
+
+// call starts fetching in the background. c.mtx must be held
+func (c *responseCache) call(key string, fetch func(ctx context.Context) (io.ReadCloser, error)) *cacheCall {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), c.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	call := &cacheCall{done: make(chan struct{}), cancel: cancel}
+	c.calls[key] = call
+
+	go func() {
+		data, stream, err := c.read(ctx, cancel, fetch)
+
+		c.mtx.Lock()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+		switch {
+		case stream != nil:
+			call.tooLarge = true
+			if call.waiters == 0 {
+				stream.Close()
+			} else {
+				call.stream = stream
+			}
+		case err != nil:
+			cancel()
+			call.err = err
+		default:
+			cancel()
+			call.data = data
+			// Don't keep errors, they may be temporary
+			if !akips.HasError(data) {
+				c.add(key, data)
+			}
+		}
+		c.mtx.Unlock()
+		close(call.done)
+	}()
+
+	return call
+}
+
+// read reads up to maxSize bytes of the response. A larger response is returned as a stream
+// which owns the call context
+func (c *responseCache) read(ctx context.Context, cancel context.CancelFunc, fetch func(ctx context.Context) (io.ReadCloser, error)) ([]byte, *callStream, error) {
+	body, err := fetch(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, int64(c.maxSize)+1))
+	if err != nil || len(data) <= c.maxSize {
+		body.Close()
+		return data, nil, err
+	}
+	return nil, &callStream{
+		Reader: io.MultiReader(bytes.NewReader(data), body),
+		body:   body,
+		cancel: cancel,
+		done:   ctx.Done(),
+	}, nil
+}
+
+// leave is called by a caller which gave up waiting
+func (c *responseCache) leave(key string, call *cacheCall) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if call.waiters--; call.waiters != 0 {
+		return
+	}
+	// Nobody is waiting for the response any more
+	call.cancel()
+	if call.stream != nil {
+		call.stream.Close()
+		call.stream = nil
+	}
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+}
+
+func (c *responseCache) add(key string, data []byte) {
+	if len(data) > c.maxSize {
+		return
+	}
+	for c.size+len(data) > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+	el := c.lru.PushFront(&cacheEntry{
+		key:     key,
+		data:    data,
+		expires: time.Now().Add(c.ttl),
+	})
+	c.entries[key] = el
+	c.size += len(data)
+}
+
+func (c *responseCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	c.size -= len(e.data)
+}
+
+// alignTimeRange widens the range to whole multiples of d so that refreshes
+// within the same interval produce the same command
+func alignTimeRange(tr backend.TimeRange, d time.Duration) backend.TimeRange {
+	to := tr.To.Truncate(d)
+	if to.Before(tr.To) {
+		to = to.Add(d)
+	}
+	return backend.TimeRange{
+		From: tr.From.Truncate(d),
+		To:   to,
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func body(s string) io.ReadCloser {
+	return ioutil.NopCloser(strings.NewReader(s))
+}
+
+func readAll(r io.ReadCloser, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	return string(data), err
+}
+
+func TestCacheCoalescing(t *testing.T) {
+	c := newResponseCache(time.Minute, 1<<20, 0)
+	var calls int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return body("response"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := readAll(c.get(context.Background(), "cmd", fetch))
+			if err != nil || data != "response" {
+				t.Errorf("got %q, %v", data, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Served from the cache
+	if _, err := readAll(c.get(context.Background(), "cmd", fetch)); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("fetch called %d times", calls)
+	}
+}
+
+func TestCacheCallerCancel(t *testing.T) {
+	c := newResponseCache(time.Minute, 1<<20, 0)
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		select {
+		case <-release:
+			return body("response"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// The first caller starts the call and gives up
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := c.get(ctx, "cmd", fetch)
+		first <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	second := make(chan error)
+	go func() {
+		data, err := readAll(c.get(context.Background(), "cmd", fetch))
+		if err == nil && data != "response" {
+			err = errors.New("unexpected response")
+		}
+		second <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller: %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("second caller: %v", err)
+	}
+}
+
+func TestCacheAllCallersCancel(t *testing.T) {
+	c := newResponseCache(time.Minute, 1<<20, 0)
+	cancelled := make(chan struct{})
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.get(ctx, "cmd", fetch)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	<-done
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the call hasn't been cancelled")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := newResponseCache(time.Minute, 10, 0)
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := readAll(c.get(context.Background(), key, func(context.Context) (io.ReadCloser, error) {
+			return body("12345"), nil
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if c.size > 10 || len(c.entries) != 2 {
+		t.Errorf("size = %d, entries = %d", c.size, len(c.entries))
+	}
+	if _, ok := c.entries["a"]; ok {
+		t.Error("the least recently used entry is still cached")
+	}
+}
+
+type closeNotifier struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (c *closeNotifier) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestCacheTooLarge(t *testing.T) {
+	c := newResponseCache(time.Minute, 4, 0)
+	var calls int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return body("too large"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := readAll(c.get(context.Background(), "cmd", fetch))
+			if err != nil || data != "too large" {
+				t.Errorf("got %q, %v", data, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if len(c.entries) != 0 {
+		t.Error("the response has been cached")
+	}
+	// The first caller takes the shared response, the others fetch their own
+	if calls != 3 {
+		t.Errorf("fetch called %d times", calls)
+	}
+}
+
+func TestCacheTooLargeCallerCancel(t *testing.T) {
+	c := newResponseCache(time.Minute, 4, 0)
+	closed := make(chan struct{})
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		return &closeNotifier{Reader: strings.NewReader("too large"), closed: closed}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := c.get(ctx, "cmd", fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// The stream is released when the caller gives up
+	cancel()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("the response hasn't been closed")
+	}
+}
+
+func TestCacheSkipsErrors(t *testing.T) {
+	c := newResponseCache(time.Minute, 1<<20, 0)
+	var calls int32
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		atomic.AddInt32(&calls, 1)
+		return body("sw1 a b = 1\nERROR: database busy\n"), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		data, err := readAll(c.get(context.Background(), "cmd", fetch))
+		if err != nil || !strings.Contains(data, "ERROR:") {
+			t.Fatalf("got %q, %v", data, err)
+		}
+	}
+	// AKiPS errors come with a successful status and aren't cached
+	if calls != 2 || len(c.entries) != 0 {
+		t.Errorf("fetch called %d times, %d entries", calls, len(c.entries))
+	}
+}
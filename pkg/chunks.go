@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+// chunk is a part of a query covering a sub-window of its time range
+type chunk struct {
+	query *query
+	cmd   string
+}
+
+func (q *query) withTimeRange(tr backend.TimeRange) *query {
+	dq := *q.query
+	dq.TimeRange = tr
+	return &query{query: &dq, model: q.model}
+}
+
+// step returns the series interval the chunk boundaries are aligned to
+func (q *query) step() time.Duration {
+	if q.isStructured() && q.model.Structured != nil && q.model.Structured.Interval > 0 {
+		return time.Duration(q.model.Structured.Interval) * time.Second
+	}
+	return time.Duration(q.interval()) * time.Second
+}
+
+// chunks splits a long time range into sub-windows. It returns nil if the query
+// isn't split, e.g. if the command doesn't depend on the dashboard time range
+func (q *query) chunks(s *datasourceSettings, cmd string) ([]*chunk, error) {
+	if s.DisableChunking {
+		return nil, nil
+	}
+	switch q.query.QueryType {
+	case "", queryTimeSeries, queryNetflow, queryNetflowTS:
+	default:
+		return nil, nil
+	}
+
+	step := q.step()
+	size := time.Duration(s.ChunkSize) * time.Hour / step * step
+	if size < step {
+		size = step
+	}
+
+	tr := q.query.TimeRange
+	if tr.Duration() <= size {
+		return nil, nil
+	}
+
+	var chunks []*chunk
+	for from := tr.From; from.Before(tr.To); from = from.Add(size) {
+		// Sub-windows don't overlap so flow counters aren't counted twice
+		to := from.Add(size - time.Second)
+		if !to.Before(tr.To) {
+			to = tr.To
+		}
+
+		cq := q.withTimeRange(backend.TimeRange{From: from, To: to})
+		c, err := cq.command()
+		if err != nil {
+			return nil, err
+		}
+		if c == cmd {
+			return nil, nil
+		}
+		chunks = append(chunks, &chunk{query: cq, cmd: c})
+	}
+
+	return chunks, nil
+}
+
+// fetchChunks executes the chunks with limited parallelism and calls fn with each response.
+// The first error cancels the remaining chunks
+func fetchChunks(ctx context.Context, inst *instanceSettings, chunks []*chunk, fn func(i int, body io.Reader) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, inst.settings.MaxConcurrentChunks)
+
+loop:
+	for i, c := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+
+		wg.Add(1)
+		go func(i int, c *chunk) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			backend.Logger.Debug("AKiPS query chunk", "refId", c.query.query.RefID, "query", inst.client.Config().Redact(c.cmd))
+			err := func() error {
+				body, err := inst.open(ctx, c.cmd)
+				if err != nil {
+					return err
+				}
+				defer body.Close()
+				return fn(i, body)
+			}()
+			if err != nil {
+				once.Do(func() {
+					firstErr = akips.WithCommand(err, inst.client.Config().Redact(c.cmd))
+					cancel()
+				})
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// doChunkedQuery executes the chunks and merges the results as if the query was run over the whole range
+func doChunkedQuery(ctx context.Context, inst *instanceSettings, q *query, chunks []*chunk) (backend.DataResponse, error) {
+	cmds := make([]string, len(chunks))
+	for i, c := range chunks {
+		cmds[i] = inst.client.Config().Redact(c.cmd)
+	}
+	meta := data.FrameMeta{ExecutedQueryString: strings.Join(cmds, "\n")}
+
+	switch q.query.QueryType {
+	case queryNetflow:
+		parts := make([]akips.NetflowResponse, len(chunks))
+		if err := fetchChunks(ctx, inst, chunks, func(i int, body io.Reader) error {
+			return parts[i].ParseResponse(body)
+		}); err != nil {
+			return backend.DataResponse{Error: err}, nil
+		}
+
+		var akipsResponse akips.NetflowResponse
+		for _, p := range parts {
+			akipsResponse.Append(p)
+		}
+		return processNetflow(akipsResponse, q, &meta)
+
+	case queryNetflowTS:
+		// Merged as frames rather than with Concat as the interval of a shorter
+		// sub-window may differ, and there's no way to represent missing values in between
+		parts := make([]backend.DataResponse, len(chunks))
+		if err := fetchChunks(ctx, inst, chunks, func(i int, body io.Reader) error {
+			var akipsResponse akips.NetflowTimeSeriesResponse
+			if err := akipsResponse.ParseResponse(body); err != nil {
+				return err
+			}
+			r, err := processNetflowTimeSeries(akipsResponse, chunks[i].query, &meta)
+			parts[i] = r
+			return err
+		}); err != nil {
+			return backend.DataResponse{Error: err}, nil
+		}
+		return mergeFrames(parts), nil
+	}
+
+	parts := make([]backend.DataResponse, len(chunks))
+	if err := fetchChunks(ctx, inst, chunks, func(i int, body io.Reader) error {
+		r, err := processSeries(body, chunks[i].query, &meta)
+		if err != nil {
+			return err
+		}
+		parts[i] = r
+		return r.Error
+	}); err != nil {
+		return backend.DataResponse{Error: err}, nil
+	}
+	return mergeFrames(parts), nil
+}
+
+// mergeFrames merges the frames of consecutive chunks belonging to the same series
+func mergeFrames(parts []backend.DataResponse) (res backend.DataResponse) {
+	index := make(map[string]*data.Frame)
+	for _, p := range parts {
+		for _, f := range p.Frames {
+			key := frameKey(f)
+			dst, ok := index[key]
+			if !ok {
+				dst = emptyFrame(f)
+				index[key] = dst
+				res.Frames = append(res.Frames, dst)
+			}
+			appendFrame(dst, f)
+		}
+	}
+	return res
+}
+
+// frameKey identifies the series of a frame by the names and labels of its value fields
+func frameKey(f *data.Frame) string {
+	var b strings.Builder
+	for _, field := range f.Fields[1:] {
+		b.WriteString(field.Name + "{" + field.Labels.String() + "}")
+	}
+	return b.String()
+}
+
+// emptyFrame returns a frame with the same structure as f and no rows.
+// Fields are never shared with f as the time field is shared between the frames of a response
+func emptyFrame(f *data.Frame) *data.Frame {
+	fields := make([]*data.Field, len(f.Fields))
+	for i, src := range f.Fields {
+		field := data.NewFieldFromFieldType(src.Type(), 0)
+		field.Name = src.Name
+		field.Labels = src.Labels
+		field.Config = src.Config
+		fields[i] = field
+	}
+	return &data.Frame{
+		Name:   f.Name,
+		Fields: fields,
+		Meta:   f.Meta,
+		RefID:  f.RefID,
+	}
+}
+
+// appendFrame appends the rows of src which follow the last row of dst.
+// The first field of both frames is the time field. Chunks of the same series may be parsed
+// as ints or floats, integer fields are converted to floats if the other frame has floats
+func appendFrame(dst, src *data.Frame) {
+	if len(dst.Fields) != len(src.Fields) {
+		return
+	}
+	for j, field := range src.Fields {
+		if dst.Fields[j].Type() == data.FieldTypeNullableInt64 && field.Type() == data.FieldTypeNullableFloat64 {
+			dst.Fields[j] = floatField(dst.Fields[j])
+		}
+	}
+
+	var last time.Time
+	if n := dst.Rows(); n != 0 {
+		last, _ = dst.Fields[0].At(n - 1).(time.Time)
+	}
+
+	for i := 0; i < src.Rows(); i++ {
+		if ts, _ := src.Fields[0].At(i).(time.Time); !ts.After(last) {
+			continue
+		}
+		for j, field := range src.Fields {
+			v := field.At(i)
+			if dst.Fields[j].Type() == data.FieldTypeNullableFloat64 {
+				v = toFloat(v)
+			}
+			dst.Fields[j].Append(v)
+		}
+	}
+}
+
+// floatField returns a nullable float64 copy of the nullable int64 field f
+func floatField(f *data.Field) *data.Field {
+	field := data.NewFieldFromFieldType(data.FieldTypeNullableFloat64, f.Len())
+	field.Name = f.Name
+	field.Labels = f.Labels
+	field.Config = f.Config
+	for i := 0; i < f.Len(); i++ {
+		field.Set(i, toFloat(f.At(i)))
+	}
+	return field
+}
+
+// toFloat converts a nullable int64 value to nullable float64. Other values are returned as is
+func toFloat(v interface{}) interface{} {
+	iv, ok := v.(*int64)
+	if !ok {
+		return v
+	}
+	if iv == nil {
+		return (*float64)(nil)
+	}
+	fv := float64(*iv)
+	return &fv
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+func seriesFrame(ts []time.Time, values interface{}) *data.Frame {
+	return &data.Frame{
+		Fields: []*data.Field{
+			data.NewField("Timestamp", nil, ts),
+			data.NewField("Gi0/1", data.Labels{"parent": "sw1"}, values),
+		},
+	}
+}
+
+func int64p(v int64) *int64 { return &v }
+
+func float64p(v float64) *float64 { return &v }
+
+func TestAppendFrameIntFloat(t *testing.T) {
+	ts := time.Date(2020, 9, 13, 12, 0, 0, 0, time.UTC)
+	ints := seriesFrame([]time.Time{ts, ts.Add(time.Minute)}, []*int64{int64p(1), nil})
+	floats := seriesFrame([]time.Time{ts.Add(2 * time.Minute), ts.Add(3 * time.Minute)}, []*float64{float64p(2.5), nil})
+	moreInts := seriesFrame([]time.Time{ts.Add(4 * time.Minute)}, []*int64{int64p(4)})
+
+	if frameKey(ints) != frameKey(floats) {
+		t.Fatal("the chunks belong to the same series")
+	}
+
+	dst := emptyFrame(ints)
+	for _, f := range []*data.Frame{ints, floats, moreInts} {
+		appendFrame(dst, f)
+	}
+
+	field := dst.Fields[1]
+	if field.Type() != data.FieldTypeNullableFloat64 {
+		t.Fatalf("type = %v, want nullable float64", field.Type())
+	}
+	want := []*float64{float64p(1), nil, float64p(2.5), nil, float64p(4)}
+	if field.Len() != len(want) || dst.Fields[0].Len() != len(want) {
+		t.Fatalf("got %d values, %d timestamps", field.Len(), dst.Fields[0].Len())
+	}
+	for i, w := range want {
+		v := field.At(i).(*float64)
+		if (v == nil) != (w == nil) || v != nil && *v != *w {
+			t.Errorf("value %d = %v, want %v", i, v, w)
+		}
+	}
+	if field.Name != "Gi0/1" || field.Labels["parent"] != "sw1" {
+		t.Errorf("unexpected field %q %v", field.Name, field.Labels)
+	}
+}
+
+func TestAppendFrameFloatInt(t *testing.T) {
+	ts := time.Date(2020, 9, 13, 12, 0, 0, 0, time.UTC)
+	floats := seriesFrame([]time.Time{ts}, []*float64{float64p(0.5)})
+	ints := seriesFrame([]time.Time{ts, ts.Add(time.Minute)}, []*int64{int64p(1), int64p(2)})
+
+	dst := emptyFrame(floats)
+	appendFrame(dst, floats)
+	appendFrame(dst, ints)
+
+	// The overlapping row is skipped
+	field := dst.Fields[1]
+	if field.Len() != 2 {
+		t.Fatalf("got %d values", field.Len())
+	}
+	if v := field.At(1).(*float64); v == nil || *v != 2 {
+		t.Errorf("unexpected value %v", v)
+	}
+}
+
+func TestChunks(t *testing.T) {
+	from := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	q := &query{
+		query: &backend.DataQuery{
+			TimeRange: backend.TimeRange{From: from, To: from.Add(50 * time.Hour)},
+			Interval:  time.Minute,
+		},
+		model: &queryModel{Query: "series avg time $__timeFrom,$__timeTo * * *"},
+	}
+	cmd, err := q.command()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := q.chunks(&datasourceSettings{ChunkSize: 24}, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+
+	// The sub-windows don't overlap and cover the whole range
+	want := []backend.TimeRange{
+		{From: from, To: from.Add(24*time.Hour - time.Second)},
+		{From: from.Add(24 * time.Hour), To: from.Add(48*time.Hour - time.Second)},
+		{From: from.Add(48 * time.Hour), To: from.Add(50 * time.Hour)},
+	}
+	for i, c := range chunks {
+		if tr := c.query.query.TimeRange; !tr.From.Equal(want[i].From) || !tr.To.Equal(want[i].To) {
+			t.Errorf("chunk %d: %v - %v, want %v - %v", i, tr.From, tr.To, want[i].From, want[i].To)
+		}
+		wantCmd := fmt.Sprintf("series avg time %d,%d * * *", want[i].From.Unix(), want[i].To.Unix())
+		if c.cmd != wantCmd {
+			t.Errorf("chunk %d: %q, want %q", i, c.cmd, wantCmd)
+		}
+	}
+	if q.query.TimeRange.From != from {
+		t.Error("the original query has been modified")
+	}
+}
+
+func TestChunksNotSplit(t *testing.T) {
+	from := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	q := &query{
+		query: &backend.DataQuery{
+			TimeRange: backend.TimeRange{From: from, To: from.Add(50 * time.Hour)},
+			Interval:  time.Minute,
+		},
+		model: &queryModel{Query: "series avg time last1d * * *"},
+	}
+	cmd, _ := q.command()
+
+	for _, s := range []*datasourceSettings{
+		// The command doesn't depend on the time range
+		{ChunkSize: 24},
+		{ChunkSize: 24, DisableChunking: true},
+		// The range fits a single chunk
+		{ChunkSize: 50},
+	} {
+		chunks, err := q.chunks(s, cmd)
+		if err != nil || chunks != nil {
+			t.Errorf("%+v: got %d chunks, %v", s, len(chunks), err)
+		}
+	}
+}
+
+func TestNetflowAppend(t *testing.T) {
+	n := func(v int64) *int64 { return &v }
+	a := akips.NetflowResponse{
+		{Source: "10.0.0.1", Destination: "10.0.0.2", Protocol: "tcp", Bytes: n(1)},
+	}
+	a.Append(akips.NetflowResponse{
+		// Same concatenation of the fields, a different flow
+		{Source: "10.0.0.11", Destination: "0.0.0.2", Protocol: "tcp", Bytes: n(10)},
+		{Source: "10.0.0.1", Destination: "10.0.0.2", Protocol: "tcp", Bytes: n(100)},
+	})
+
+	if len(a) != 2 {
+		t.Fatalf("got %d flows", len(a))
+	}
+	if *a[0].Bytes != 101 || *a[1].Bytes != 10 {
+		t.Errorf("bytes = %d, %d", *a[0].Bytes, *a[1].Bytes)
+	}
+}
+
+func TestNetflowTimeSeriesConcat(t *testing.T) {
+	start := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	series := func(offset time.Duration, interval int64, values ...int64) akips.NetflowTimeSeriesResponse {
+		return akips.NetflowTimeSeriesResponse{
+			"bytes": {Start: start.Add(offset), Interval: interval, Values: values},
+		}
+	}
+
+	var res akips.NetflowTimeSeriesResponse
+	for _, p := range []akips.NetflowTimeSeriesResponse{
+		series(0, 60, 1, 2),
+		// Overlapping values are skipped
+		series(time.Minute, 60, 2, 3),
+		series(3*time.Minute, 60, 4),
+	} {
+		if err := res.Concat(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := res["bytes"].Values; !reflect.DeepEqual(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("values = %v", got)
+	}
+
+	for name, p := range map[string]akips.NetflowTimeSeriesResponse{
+		"interval":   series(4*time.Minute, 300, 5),
+		"gap":        series(10*time.Minute, 60, 5),
+		"misaligned": series(4*time.Minute+time.Second, 60, 5),
+		"preceding":  series(-time.Minute, 60, 5),
+	} {
+		if err := res.Concat(p); !errors.Is(err, akips.ErrConcat) {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+	// Values aren't invented or dropped
+	if got := res["bytes"].Values; !reflect.DeepEqual(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("values = %v", got)
+	}
+}
+
+func TestMergeNetflowTimeSeriesChunks(t *testing.T) {
+	start := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	q := &query{query: &backend.DataQuery{RefID: "A"}, model: &queryModel{}}
+	var parts []backend.DataResponse
+	for _, p := range []akips.NetflowTimeSeriesResponse{
+		{"bytes": {Start: start, Interval: 60, Values: []int64{1, 2}}},
+		// A shorter last sub-window with a different interval and a gap
+		{"bytes": {Start: start.Add(10 * time.Minute), Interval: 300, Values: []int64{3}}},
+	} {
+		r, err := processNetflowTimeSeries(p, q, &data.FrameMeta{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, r)
+	}
+
+	res := mergeFrames(parts)
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames", len(res.Frames))
+	}
+	f := res.Frames[0]
+	if f.Rows() != 3 {
+		t.Fatalf("got %d rows", f.Rows())
+	}
+	if ts := f.Fields[0].At(2).(time.Time); !ts.Equal(start.Add(10 * time.Minute)) {
+		t.Errorf("time = %v", ts)
+	}
+	if v := f.Fields[1].At(2).(int64); v != 3 {
+		t.Errorf("value = %d", v)
+	}
+}
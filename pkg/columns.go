@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Column types of table and CSV queries
+const (
+	columnInt    = "int"
+	columnFloat  = "float"
+	columnBool   = "bool"
+	columnTime   = "time"
+	columnString = "string"
+)
+
+// Candidate types in order of preference
+var columnTypes = [...]string{columnInt, columnFloat, columnBool, columnTime, columnString}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+// parseColumnValue converts s to the column type. It returns nil if s doesn't match the type
+func parseColumnValue(typ, s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	switch typ {
+	case columnInt:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &v
+		}
+	case columnFloat:
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return &v
+		}
+	case columnBool:
+		if v, ok := parseBool(s); ok {
+			return &v
+		}
+	case columnTime:
+		if v, ok := parseTime(s); ok {
+			return &v
+		}
+	default:
+		return &s
+	}
+	return nil
+}
+
+// columnInference narrows down the type of a column value by value
+type columnInference struct {
+	rejected [len(columnTypes)]bool
+	seen     bool
+}
+
+func (c *columnInference) add(s string) {
+	if s == "" {
+		return
+	}
+	c.seen = true
+	for i, typ := range columnTypes {
+		if !c.rejected[i] && parseColumnValue(typ, s) == nil {
+			c.rejected[i] = true
+		}
+	}
+}
+
+// columnType returns the first type matching all values. Columns without values are strings
+func (c *columnInference) columnType() string {
+	if !c.seen {
+		return columnString
+	}
+	for i, typ := range columnTypes {
+		if !c.rejected[i] {
+			return typ
+		}
+	}
+	return columnString
+}
+
+func columnConverter(typ string) data.FieldConverter {
+	var ft data.FieldType
+	switch typ {
+	case columnInt:
+		ft = data.FieldTypeNullableInt64
+	case columnFloat:
+		ft = data.FieldTypeNullableFloat64
+	case columnBool:
+		ft = data.FieldTypeNullableBool
+	case columnTime:
+		ft = data.FieldTypeNullableTime
+	default:
+		ft = data.FieldTypeNullableString
+	}
+	return data.FieldConverter{
+		OutputFieldType: ft,
+		Converter: func(v interface{}) (interface{}, error) {
+			s, _ := v.(string)
+			return parseColumnValue(typ, s), nil
+		},
+	}
+}
+
+// columnConverters returns converters for the named columns. The types are either
+// taken from the query model or inferred from all rows
+func (q *query) columnConverters(names []string, rows int, value func(row, col int) string) ([]data.FieldConverter, error) {
+	cvt := make([]data.FieldConverter, len(names))
+	for col, name := range names {
+		typ, ok := q.model.ColumnTypes[name]
+		if ok {
+			if !isColumnType(typ) {
+				return nil, fmt.Errorf("invalid type of column %q: %q", name, typ)
+			}
+		} else {
+			var inf columnInference
+			for row := 0; row < rows; row++ {
+				inf.add(value(row, col))
+			}
+			typ = inf.columnType()
+		}
+		cvt[col] = columnConverter(typ)
+	}
+	return cvt, nil
+}
+
+func isColumnType(s string) bool {
+	for _, typ := range columnTypes {
+		if s == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -1,28 +1,48 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
+	"io"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
-	"github.com/grafana/grafana-plugin-sdk-go/data/converters"
 	"github.com/reddercode/akips-grafana/pkg/akips"
 )
 
 const minInterval = 60 * time.Second
 
 func newDatasource() *AKIPSDatasource {
-	return &AKIPSDatasource{}
+	ds := &AKIPSDatasource{
+		im: datasource.NewInstanceManager(newInstanceSettings),
+	}
+	ds.resourceHandler = ds.newResourceHandler()
+	return ds
 }
 
 // AKIPSDatasource represents AKiPS datasource
 type AKIPSDatasource struct {
+	im              instancemgmt.InstanceManager
+	resourceHandler backend.CallResourceHandler
+}
+
+func (a *AKIPSDatasource) getInstance(pc *backend.PluginContext) (*instanceSettings, error) {
+	inst, err := a.im.Get(*pc)
+	if err != nil {
+		return nil, err
+	}
+	return inst.(*instanceSettings), nil
 }
 
 type query struct {
@@ -36,27 +56,66 @@ type queryModel struct {
 	Child       string `json:"child"`
 	Attribute   string `json:"attribute"`
 	OmitParents bool   `json:"omitParents"`
-}
 
-func akipsConfig(pc *backend.PluginContext) *akips.Config {
-	is := pc.DataSourceInstanceSettings
-	return &akips.Config{
-		URL:        is.URL,
-		AuthMethod: akips.PasswordAuth(is.DecryptedSecureJSONData["password"]),
-	}
+	// Mode is either empty for free text queries or "structured"
+	Mode       string           `json:"mode"`
+	Structured *structuredModel `json:"structured"`
+	// ColumnTypes overrides the inferred types of table and CSV columns by name
+	ColumnTypes map[string]string `json:"columnTypes"`
 }
 
 // QueryData is the primary method called by grafana-server
 func (a *AKIPSDatasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
-	cfg := akipsConfig(&req.PluginContext)
+	inst, err := a.getInstance(&req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+
 	res := backend.NewQueryDataResponse()
-	for _, q := range req.Queries {
-		r, err := a.doQuery(ctx, cfg, &q)
-		if err != nil {
-			return nil, err
+
+	var (
+		wg  sync.WaitGroup
+		mtx sync.Mutex
+	)
+	sem := make(chan struct{}, inst.settings.MaxConcurrentQueries)
+
+	setResponse := func(refID string, r backend.DataResponse) {
+		mtx.Lock()
+		res.Responses[refID] = r
+		mtx.Unlock()
+	}
+
+	for i := range req.Queries {
+		q := &req.Queries[i]
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			setResponse(q.RefID, backend.DataResponse{Error: ctx.Err()})
+			continue
 		}
-		res.Responses[q.RefID] = r
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			r, err := a.doQuery(ctx, inst, q)
+			if err != nil {
+				// Don't let a single query fail the whole request
+				r = backend.DataResponse{Error: err}
+			}
+			if r.Error != nil {
+				// The error may contain the request URL along with the password
+				r.Error = inst.client.Config().RedactError(toUserError(r.Error))
+				backend.Logger.Warn("AKiPS query failed", "refId", q.RefID, "error", r.Error.Error())
+			}
+			setResponse(q.RefID, r)
+		}()
 	}
+	wg.Wait()
 
 	return res, nil
 }
@@ -65,57 +124,130 @@ const (
 	queryTable      = "table"
 	queryTimeSeries = "time_series"
 	queryCSV        = "csv"
+	queryNetflow    = "netflow"
+	queryNetflowTS  = "netflow_time_series"
+	queryMessages   = "messages"
+	queryAttributes = "attributes"
+	queryAnnotation = "annotations"
 )
 
-func (a *AKIPSDatasource) doQuery(ctx context.Context, clientConfig *akips.Config, dq *backend.DataQuery) (backend.DataResponse, error) {
+func (a *AKIPSDatasource) doQuery(ctx context.Context, inst *instanceSettings, dq *backend.DataQuery) (dr backend.DataResponse, err error) {
 	var model queryModel
 	if err := json.Unmarshal(dq.JSON, &model); err != nil {
 		return backend.DataResponse{}, err
 	}
+	if inst.cache != nil {
+		aligned := *dq
+		aligned.TimeRange = alignTimeRange(dq.TimeRange, minInterval)
+		dq = &aligned
+	}
 	query := query{
 		query: dq,
 		model: &model,
 	}
 
-	client := clientConfig.Client()
-
-	queryStr := query.interpolateVariables()
-	req, err := clientConfig.NewRequest(ctx, "GET", "/api-db", url.Values{"cmds": []string{queryStr}})
+	queryStr, err := query.command()
 	if err != nil {
-		return backend.DataResponse{}, err
+		return backend.DataResponse{Error: fmt.Errorf("invalid query: %v", err)}, nil
 	}
 
-	res, err := client.Do(req)
-	if err != nil {
-		return backend.DataResponse{Error: err}, nil
+	if chunks, err := query.chunks(inst.settings, queryStr); err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("invalid query: %v", err)}, nil
+	} else if len(chunks) > 1 {
+		return doChunkedQuery(ctx, inst, &query, chunks)
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode/100 != 2 {
+	backend.Logger.Debug("AKiPS query", "refId", dq.RefID, "query", inst.client.Config().Redact(queryStr))
+	defer func() {
+		dr.Error = akips.WithCommand(dr.Error, inst.client.Config().Redact(queryStr))
+	}()
+
+	body, err := inst.open(ctx, queryStr)
+	if err != nil {
 		return backend.DataResponse{Error: err}, nil
 	}
+	defer body.Close()
 
-	meta := data.FrameMeta{ExecutedQueryString: queryStr}
+	meta := data.FrameMeta{ExecutedQueryString: inst.client.Config().Redact(queryStr)}
 
-	if query.query.QueryType == "csv" {
+	switch query.query.QueryType {
+	case queryCSV:
 		var akipsResponse akips.CSVResponse
-		if err := akipsResponse.ParseResponse(res.Body); err != nil {
+		if err := akipsResponse.ParseResponse(body); err != nil {
 			return backend.DataResponse{Error: err}, nil
 		}
 		return processCSV(akipsResponse, &query, &meta)
-	}
 
-	var akipsResponse akips.GenericResponse
-	if err := akipsResponse.ParseResponse(res.Body); err != nil {
-		return backend.DataResponse{Error: err}, nil
+	case queryNetflow:
+		var akipsResponse akips.NetflowResponse
+		if err := akipsResponse.ParseResponse(body); err != nil {
+			return backend.DataResponse{Error: err}, nil
+		}
+		return processNetflow(akipsResponse, &query, &meta)
+
+	case queryNetflowTS:
+		var akipsResponse akips.NetflowTimeSeriesResponse
+		if err := akipsResponse.ParseResponse(body); err != nil {
+			return backend.DataResponse{Error: err}, nil
+		}
+		return processNetflowTimeSeries(akipsResponse, &query, &meta)
+
+	case queryMessages:
+		var akipsResponse akips.MsgResponse
+		if err := akipsResponse.ParseResponse(body); err != nil {
+			return backend.DataResponse{Error: err}, nil
+		}
+		return processMessages(akipsResponse, &query, &meta)
+
+	case queryAttributes:
+		return processAttributes(akips.NewAttributeDecoder(body, attributeType(queryStr)), &query, &meta)
 	}
 
-	switch query.query.QueryType {
-	case queryTable:
+	if query.query.QueryType == queryTable {
+		var akipsResponse akips.GenericResponse
+		if err := akipsResponse.ParseResponse(body); err != nil {
+			return backend.DataResponse{Error: err}, nil
+		}
 		return processTable(akipsResponse, &query, &meta)
-	default:
-		return processTimeSeries(akipsResponse, &query, &meta)
 	}
+
+	return processSeries(body, &query, &meta)
+}
+
+// command returns the command string of either a structured or a free text query
+func (q *query) command() (string, error) {
+	if q.isStructured() {
+		return q.buildCommand()
+	}
+	return q.interpolateVariables(), nil
+}
+
+// processSeries handles both time series formats as well as annotations
+func processSeries(body io.Reader, query *query, frameMeta *data.FrameMeta) (backend.DataResponse, error) {
+	// Look at the first line to choose the format without reading the whole response
+	br := bufio.NewReader(body)
+	first := peekLine(br)
+
+	if query.query.QueryType == queryAnnotation {
+		return processAnnotations(br, first, query, frameMeta)
+	}
+
+	if akips.IsTimeSeriesHeader(string(first)) {
+		return processCSVSeries(akips.NewTimeSeriesDecoder(br), query, frameMeta)
+	}
+
+	// Legacy `parent child attribute = value,...` format
+	return processTimeSeries(akips.NewGenericDecoder(br), query, frameMeta)
+}
+
+// peekLine returns the beginning of the first line without consuming it
+func peekLine(br *bufio.Reader) []byte {
+	// io.EOF or bufio.ErrBufferFull are fine here
+	line, _ := br.Peek(br.Size())
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return line
 }
 
 func fieldName(e *akips.GenericResponseEntry) string {
@@ -137,7 +269,7 @@ func (q *query) interpolateVariables() string {
 		return re.ReplaceAllString(s, val+"$2")
 	}
 
-	interval := int64(((q.query.Interval + minInterval - 1) / minInterval) * minInterval / time.Second)
+	interval := q.interval()
 	from := q.query.TimeRange.From.Unix()
 	to := q.query.TimeRange.To.Unix()
 
@@ -186,14 +318,11 @@ func (q *query) mkTimestampField(n int) *data.Field {
 	return data.NewField("Timestamp", nil, ts)
 }
 
-func processTimeSeries(akipsResponse akips.GenericResponse, query *query, frameMeta *data.FrameMeta) (res backend.DataResponse, err error) {
-	if len(akipsResponse) == 0 {
-		return
-	}
-
+func processTimeSeries(dec *akips.GenericDecoder, query *query, frameMeta *data.FrameMeta) (res backend.DataResponse, err error) {
 	var tsField *data.Field
 
-	for _, line := range akipsResponse {
+	for dec.Next() {
+		line := dec.Entry()
 		if len(line.Values) == 0 {
 			// unlikely
 			continue
@@ -203,26 +332,99 @@ func processTimeSeries(akipsResponse akips.GenericResponse, query *query, frameM
 			tsField = query.mkTimestampField(len(line.Values))
 		}
 
-		var datapoints []*int64 // nullable
-		datapoints = make([]*int64, len(line.Values))
-		for i, v := range line.Values {
-			if vv, err := strconv.ParseInt(v, 10, 64); err == nil {
-				datapoints[i] = &vv
-			}
-		}
+		ints, floats, invalid := akips.ParseValues(line.Values)
 
 		fn := fieldName(line)
-		df := data.NewField(fn, fieldLabels(line), datapoints)
+		df := data.NewField(fn, fieldLabels(line), datapoints(ints, floats, len(line.Values)))
 
 		// Frame per line
 		res.Frames = append(res.Frames, &data.Frame{
 			// Name:   fn,
 			Fields: []*data.Field{tsField, df},
-			Meta:   frameMeta,
+			Meta:   invalidValuesMeta(frameMeta, fn, invalid),
+			RefID:  query.query.RefID,
+		})
+	}
+
+	if err := dec.Err(); err != nil {
+		return backend.DataResponse{Error: err}, nil
+	}
+
+	return
+}
+
+// datapoints returns the values parsed by akips.ParseValues as a field vector of n rows.
+// Short lines are padded with nulls
+func datapoints(ints []*int64, floats []*float64, n int) interface{} {
+	if floats != nil {
+		if len(floats) != n {
+			floats = append(make([]*float64, 0, n), floats...)[:n]
+		}
+		return floats
+	}
+	if len(ints) != n {
+		ints = append(make([]*int64, 0, n), ints...)[:n]
+	}
+	return ints
+}
+
+// invalidValuesMeta returns a copy of meta with a warning about the values of field fn which
+// could not be parsed, or meta itself if there are none
+func invalidValuesMeta(meta *data.FrameMeta, fn string, invalid []string) *data.FrameMeta {
+	if len(invalid) == 0 {
+		return meta
+	}
+	m := *meta
+	m.Notices = append(append([]data.Notice(nil), m.Notices...), data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("%s: %d value(s) could not be parsed, e.g. %q", fn, len(invalid), invalid[0]),
+	})
+	return &m
+}
+
+func processCSVSeries(dec *akips.TimeSeriesDecoder, query *query, frameMeta *data.FrameMeta) (res backend.DataResponse, err error) {
+	var tsField *data.Field
+
+	for dec.Next() {
+		line := dec.Entry()
+
+		if tsField == nil {
+			tsField = data.NewField("Timestamp", nil, dec.Timestamp())
+		}
+
+		labels := make(data.Labels, 4)
+		if line.Parent != "" {
+			labels["parent"] = line.Parent
+		}
+		if line.Child != "" {
+			labels["child"] = line.Child
+		}
+		if line.ChildDescription != "" {
+			labels["description"] = line.ChildDescription
+		}
+		if line.Attribute != "" {
+			labels["attribute"] = line.Attribute
+		}
+
+		fn := fieldName(&akips.GenericResponseEntry{
+			Parent:    line.Parent,
+			Child:     line.Child,
+			Attribute: line.Attribute,
+		})
+		df := data.NewField(fn, labels, datapoints(line.Values, line.FloatValues, len(dec.Timestamp())))
+
+		// Frame per line
+		res.Frames = append(res.Frames, &data.Frame{
+			Fields: []*data.Field{tsField, df},
+			Meta:   invalidValuesMeta(frameMeta, fn, line.Invalid),
 			RefID:  query.query.RefID,
 		})
 	}
 
+	if err := dec.Err(); err != nil {
+		return backend.DataResponse{Error: err}, nil
+	}
+
 	return
 }
 
@@ -247,30 +449,33 @@ func processTable(akipsResponse akips.GenericResponse, query *query, frameMeta *
 		)
 	}
 
-	// guess fields' formats
-	for i := 0; i < vlen; i++ {
-		c := converters.AnyToNullableString
-		if i < len(akipsResponse[0].Values) {
-			if _, err := strconv.ParseInt(akipsResponse[0].Values[i], 10, 64); err == nil {
-				c = converters.StringToNullableFloat64
-			}
-		}
-		cvt = append(cvt, c)
-	}
-
-	builder, err := data.NewFrameInputConverter(cvt, len(akipsResponse))
-	if err != nil {
-		return backend.DataResponse{Error: err}, nil
-	}
-
 	names := make([]string, 0, vlen+3)
 	if !query.model.OmitParents {
 		names = append(names, "Parent", "Child", "Attribute")
 	} else {
 		names = append(names, "Name")
 	}
-	for i := 0; i < vlen; i++ {
-		names = append(names, fmt.Sprintf("Value #%d", i))
+	valueNames := make([]string, vlen)
+	for i := range valueNames {
+		valueNames[i] = fmt.Sprintf("Value #%d", i)
+	}
+	names = append(names, valueNames...)
+
+	// fields' formats
+	valueCvt, err := query.columnConverters(valueNames, len(akipsResponse), func(row, col int) string {
+		if v := akipsResponse[row].Values; col < len(v) {
+			return v[col]
+		}
+		return ""
+	})
+	if err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("invalid query: %v", err)}, nil
+	}
+	cvt = append(cvt, valueCvt...)
+
+	builder, err := data.NewFrameInputConverter(cvt, len(akipsResponse))
+	if err != nil {
+		return backend.DataResponse{Error: err}, nil
 	}
 
 	if err := builder.Frame.SetFieldNames(names...); err != nil {
@@ -291,11 +496,7 @@ func processTable(akipsResponse akips.GenericResponse, query *query, frameMeta *
 		}
 
 		for fi, v := range line.Values {
-			var val interface{}
-			if v != "" {
-				val = v
-			}
-			builder.Set(fi+offset, i, val)
+			builder.Set(fi+offset, i, v)
 		}
 	}
 
@@ -318,17 +519,20 @@ func processCSV(akipsResponse akips.CSVResponse, query *query, frameMeta *data.F
 		}
 	}
 
-	cvt := make([]data.FieldConverter, vlen)
+	names := make([]string, vlen)
+	for i := range names {
+		names[i] = fmt.Sprintf("Value #%d", i)
+	}
 
-	// guess fields' formats
-	for i := range cvt {
-		c := converters.AnyToNullableString
-		if i < len(akipsResponse[0]) {
-			if _, err := strconv.ParseInt(akipsResponse[0][i], 10, 64); err == nil {
-				c = converters.StringToNullableFloat64
-			}
+	// fields' formats
+	cvt, err := query.columnConverters(names, len(akipsResponse), func(row, col int) string {
+		if v := akipsResponse[row]; col < len(v) {
+			return v[col]
 		}
-		cvt[i] = c
+		return ""
+	})
+	if err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("invalid query: %v", err)}, nil
 	}
 
 	builder, err := data.NewFrameInputConverter(cvt, len(akipsResponse))
@@ -336,10 +540,6 @@ func processCSV(akipsResponse akips.CSVResponse, query *query, frameMeta *data.F
 		return backend.DataResponse{Error: err}, nil
 	}
 
-	names := make([]string, vlen)
-	for i := range names {
-		names[i] = fmt.Sprintf("Value #%d", i)
-	}
 	if err := builder.Frame.SetFieldNames(names...); err != nil {
 		return backend.DataResponse{Error: err}, nil
 	}
@@ -347,11 +547,7 @@ func processCSV(akipsResponse akips.CSVResponse, query *query, frameMeta *data.F
 	// fill the frame
 	for i, line := range akipsResponse {
 		for fi, v := range line {
-			var val interface{}
-			if v != "" {
-				val = v
-			}
-			builder.Set(fi, i, val)
+			builder.Set(fi, i, v)
 		}
 	}
 
@@ -362,37 +558,169 @@ func processCSV(akipsResponse akips.CSVResponse, query *query, frameMeta *data.F
 	return
 }
 
-// CheckHealth handles health checks
-func (a *AKIPSDatasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
-	cfg := akipsConfig(&req.PluginContext)
-	client := cfg.Client()
+func processNetflow(akipsResponse akips.NetflowResponse, query *query, frameMeta *data.FrameMeta) (res backend.DataResponse, err error) {
+	if len(akipsResponse) == 0 {
+		return
+	}
 
-	akipsReq, err := cfg.NewRequest(ctx, "GET", "/api-db", url.Values{"cmds": []string{"mget device __dummy__"}})
-	if err != nil {
-		return nil, err
+	n := len(akipsResponse)
+	var (
+		src     = make([]string, n)
+		dst     = make([]string, n)
+		proto   = make([]string, n)
+		packets = make([]*int64, n)
+		bytes   = make([]*int64, n)
+		flows   = make([]*int64, n)
+		conv    = make([]*int64, n)
+		filter  = make([]string, n)
+		urls    = make([]string, n)
+	)
+
+	var hasURL bool
+	for i, e := range akipsResponse {
+		src[i] = e.Source
+		dst[i] = e.Destination
+		proto[i] = e.Protocol
+		packets[i] = e.Packets
+		bytes[i] = e.Bytes
+		flows[i] = e.Flows
+		conv[i] = e.Conversations
+		filter[i] = e.Filter
+		urls[i] = e.URL
+		if e.URL != "" {
+			hasURL = true
+		}
 	}
 
-	res, err := client.Do(akipsReq)
-	if err != nil {
-		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: err.Error(),
-		}, nil
+	srcField := data.NewField("Source", nil, src)
+	if hasURL {
+		// Let the user drill down into AKiPS flow reports right from the table
+		srcField.SetConfig(&data.FieldConfig{
+			Links: []data.DataLink{
+				{Title: "Open in AKiPS", TargetBlank: true, URL: "${__data.fields.URL}"},
+			},
+		})
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode/100 != 2 {
-		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: res.Status,
-		}, nil
+	frame := data.NewFrame("",
+		srcField,
+		data.NewField("Destination", nil, dst),
+		data.NewField("Protocol", nil, proto),
+		data.NewField("Packets", nil, packets),
+		data.NewField("Bytes", nil, bytes),
+		data.NewField("Flows", nil, flows),
+		data.NewField("Conversations", nil, conv),
+		data.NewField("Filter", nil, filter),
+		data.NewField("URL", nil, urls),
+	)
+	frame.RefID = query.query.RefID
+	frame.Meta = frameMeta
+	res.Frames = data.Frames{frame}
+
+	return
+}
+
+func netflowLabels(e *akips.NetflowTimeSeries) (l data.Labels) {
+	l = make(data.Labels, 3)
+	if e.Source != "" {
+		l["source"] = e.Source
+	}
+	if e.Destination != "" {
+		l["destination"] = e.Destination
+	}
+	if e.Protocol != "" {
+		l["protocol"] = e.Protocol
+	}
+	return
+}
+
+func processNetflowTimeSeries(akipsResponse akips.NetflowTimeSeriesResponse, query *query, frameMeta *data.FrameMeta) (res backend.DataResponse, err error) {
+	if len(akipsResponse) == 0 {
+		return
+	}
+
+	// Keep the frames order stable between refreshes
+	names := make([]string, 0, len(akipsResponse))
+	for name := range akipsResponse {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		e := akipsResponse[name]
+		if len(e.Values) == 0 {
+			continue
+		}
+
+		// Each entry carries its own time base
+		ts := make([]time.Time, len(e.Values))
+		for i := range ts {
+			ts[i] = e.Start.Add(time.Duration(e.Interval*int64(i)) * time.Second)
+		}
+
+		res.Frames = append(res.Frames, &data.Frame{
+			Fields: []*data.Field{
+				data.NewField("Timestamp", nil, ts),
+				data.NewField(name, netflowLabels(e), e.Values),
+			},
+			Meta:  frameMeta,
+			RefID: query.query.RefID,
+		})
+	}
+
+	return
+}
+
+func processMessages(akipsResponse akips.MsgResponse, query *query, frameMeta *data.FrameMeta) (res backend.DataResponse, err error) {
+	if len(akipsResponse) == 0 {
+		return
+	}
+
+	n := len(akipsResponse)
+	var (
+		ts    = make([]time.Time, n)
+		typ   = make([]string, n)
+		ipver = make([]int64, n)
+		addr  = make([]string, n)
+		msg   = make([]string, n)
+	)
+
+	for i, e := range akipsResponse {
+		ts[i] = e.Timestamp
+		typ[i] = e.Type
+		ipver[i] = int64(e.IPVer)
+		addr[i] = e.Addr
+		msg[i] = strings.Join(e.Msg, "\n")
+	}
+
+	meta := *frameMeta
+	meta.PreferredVisualization = data.VisTypeLogs
+
+	frame := data.NewFrame("",
+		data.NewField("Timestamp", nil, ts),
+		data.NewField("Message", nil, msg),
+		data.NewField("Type", nil, typ),
+		data.NewField("Address", nil, addr),
+		data.NewField("IP version", nil, ipver),
+	)
+	frame.RefID = query.query.RefID
+	frame.Meta = &meta
+	res.Frames = data.Frames{frame}
+
+	return
+}
+
+// CheckHealth handles health checks
+func (a *AKIPSDatasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	inst, err := a.getInstance(&req.PluginContext)
+	if err != nil {
+		return nil, err
 	}
 
-	var akipsResponse akips.TestResponse
-	if err := akipsResponse.ParseResponse(res.Body); err != nil {
+	if err := inst.client.Ping(ctx); err != nil {
 		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: err.Error(),
+			Status:  healthStatus(err),
+			Message: inst.client.Config().RedactError(toUserError(err)).Error(),
 		}, nil
 	}
 
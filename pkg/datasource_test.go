@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+func TestProcessCSVSeries(t *testing.T) {
+	const input = `Device,Child,Description,Attribute,2020-09-13 12:00,2020-09-13 12:01,2020-09-13 12:02
+sw1,Gi0/1,,IF-MIB.ifInOctets,1,,3
+sw1,Gi0/2,,IF-MIB.ifInOctets,1.5,x
+`
+	q := &query{query: &backend.DataQuery{RefID: "A"}, model: &queryModel{}}
+	res, err := processCSVSeries(akips.NewTimeSeriesDecoder(strings.NewReader(input)), q, &data.FrameMeta{})
+	if err != nil || res.Error != nil {
+		t.Fatal(err, res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("got %d frames", len(res.Frames))
+	}
+
+	ints := res.Frames[0].Fields[1]
+	if v, ok := ints.At(0).(*int64); !ok || *v != 1 {
+		t.Errorf("unexpected value %v", ints.At(0))
+	}
+	if v := ints.At(1).(*int64); v != nil {
+		t.Errorf("empty cell = %v, want nil", *v)
+	}
+
+	// The line is returned as floats, the invalid and missing cells are null
+	floats := res.Frames[1].Fields[1]
+	if floats.Len() != 3 {
+		t.Fatalf("got %d values", floats.Len())
+	}
+	if v, ok := floats.At(0).(*float64); !ok || *v != 1.5 {
+		t.Errorf("unexpected value %v", floats.At(0))
+	}
+	if floats.At(1).(*float64) != nil || floats.At(2).(*float64) != nil {
+		t.Error("null values expected")
+	}
+	if notices := res.Frames[1].Meta.Notices; len(notices) != 1 {
+		t.Errorf("got %d notices", len(notices))
+	}
+	if notices := res.Frames[0].Meta.Notices; len(notices) != 0 {
+		t.Errorf("got %d notices", len(notices))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+// userError carries a message suitable for displaying in a panel or a health check result
+type userError struct {
+	msg string
+	err error
+}
+
+func (u *userError) Error() string { return u.msg }
+
+func (u *userError) Unwrap() error { return u.err }
+
+// toUserError explains the error depending on where it came from: AKiPS itself,
+// the HTTP layer, the response parser or the network
+func toUserError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		ue     *userError
+		ae     *akips.Error
+		se     *akips.StatusError
+		ne     net.Error
+		urlErr *url.Error
+		msg    string
+	)
+
+	switch {
+	case errors.As(err, &ue):
+		return err
+
+	case errors.As(err, &ae):
+		msg = "AKiPS command failed: " + ae.Message
+		if ae.Command != "" {
+			msg += " (command: " + ae.Command + ")"
+		}
+
+	case errors.As(err, &se):
+		switch se.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			msg = "AKiPS authentication failed, check the password: " + err.Error()
+		case http.StatusNotFound:
+			msg = "AKiPS API not found, check the URL: " + err.Error()
+		default:
+			msg = "AKiPS server error: " + err.Error()
+		}
+
+	case errors.Is(err, akips.ErrParse), errors.Is(err, akips.ErrLineTooLong):
+		msg = "Unexpected AKiPS response: " + err.Error()
+
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		msg = "AKiPS request timed out: " + err.Error()
+
+	case errors.As(err, &urlErr):
+		msg = "Failed to connect to AKiPS: " + err.Error()
+
+	default:
+		return err
+	}
+
+	return &userError{msg: msg, err: err}
+}
+
+// errorStatus returns an HTTP status code corresponding to the error
+func errorStatus(err error) int {
+	var (
+		ae *akips.Error
+		se *akips.StatusError
+		ne net.Error
+	)
+
+	switch {
+	case errors.As(err, &ae):
+		return http.StatusBadRequest
+	case errors.As(err, &se):
+		if se.StatusCode == http.StatusUnauthorized || se.StatusCode == http.StatusForbidden {
+			return se.StatusCode
+		}
+		return http.StatusBadGateway
+	case errors.Is(err, context.Canceled):
+		return 499 // Client closed request
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// healthStatus returns the health check status corresponding to the error
+func healthStatus(err error) backend.HealthStatus {
+	if errors.Is(err, context.Canceled) {
+		return backend.HealthStatusUnknown
+	}
+	return backend.HealthStatusError
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+func TestUserErrorRedacted(t *testing.T) {
+	const secret = "s3cret"
+	cfg := &akips.Config{AuthMethod: akips.PasswordAuth(secret)}
+
+	// A command referring to the secret attached without redaction
+	err := akips.WithCommand(&akips.Error{Message: "bad command " + secret}, "mget * "+secret+" *")
+	err = cfg.RedactError(toUserError(err))
+
+	if msg := err.Error(); strings.Contains(msg, secret) {
+		t.Errorf("%q contains the secret", msg)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "AKiPS command failed") {
+		t.Errorf("unexpected message %q", msg)
+	}
+	var ae *akips.Error
+	if !errors.As(err, &ae) {
+		t.Error("the original error isn't wrapped")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+// instanceSettings holds the long lived per-datasource state.
+// It's rebuilt by the instance manager every time the datasource settings change
+type instanceSettings struct {
+	settings  *datasourceSettings
+	transport *http.Transport
+	client    *akips.Client
+	cache     *responseCache // nil if disabled
+}
+
+func newInstanceSettings(is backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+	settings, err := loadSettings(&is)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := settings.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &akips.Config{
+		URL:        is.URL,
+		AuthMethod: authMethod(&is),
+		Transport:  transport,
+		Method:     settings.HTTPMethod,
+		Timeout:    time.Duration(settings.Timeout) * time.Second,
+	}
+
+	inst := instanceSettings{
+		settings:  settings,
+		transport: transport,
+		client:    akips.NewClient(cfg),
+	}
+	if !settings.DisableCache {
+		inst.cache = newResponseCache(time.Duration(settings.CacheTTL)*time.Second, settings.CacheMaxSize<<20, cfg.Timeout)
+	}
+
+	return &inst, nil
+}
+
+// open executes the command using the cache if it's enabled
+func (inst *instanceSettings) open(ctx context.Context, cmd string) (io.ReadCloser, error) {
+	if inst.cache == nil {
+		return inst.client.Open(ctx, cmd)
+	}
+
+	return inst.cache.get(ctx, cmd, func(ctx context.Context) (io.ReadCloser, error) {
+		return inst.client.Open(ctx, cmd)
+	})
+}
+
+// authMethod combines the reverse proxy credentials, if any, with the AKiPS password
+func authMethod(is *backend.DataSourceInstanceSettings) akips.AuthMethod {
+	secure := is.DecryptedSecureJSONData
+
+	var chain akips.ChainAuth
+	if is.BasicAuthEnabled {
+		chain = append(chain, &akips.BasicAuth{
+			Username: is.BasicAuthUser,
+			Password: secure["basicAuthPassword"],
+		})
+	}
+	if token := secure["bearerToken"]; token != "" {
+		chain = append(chain, akips.BearerAuth(token))
+	}
+
+	var jsonData map[string]interface{}
+	if len(is.JSONData) != 0 {
+		// Already validated by loadSettings
+		_ = json.Unmarshal(is.JSONData, &jsonData)
+	}
+	for i := 1; ; i++ {
+		name, _ := jsonData[fmt.Sprintf("httpHeaderName%d", i)].(string)
+		if name == "" {
+			break
+		}
+		chain = append(chain, &akips.HeaderAuth{
+			Name:  name,
+			Value: secure[fmt.Sprintf("httpHeaderValue%d", i)],
+		})
+	}
+
+	password := akips.PasswordAuth(secure["password"])
+	if len(chain) == 0 {
+		return password
+	}
+	return append(chain, password)
+}
+
+// Dispose is called by the instance manager before the instance is replaced
+func (s *instanceSettings) Dispose() {
+	s.transport.CloseIdleConnections()
+}
+
+var _ instancemgmt.InstanceDisposer = &instanceSettings{}
@@ -12,8 +12,9 @@ func main() {
 
 	ds := newDatasource()
 	err := backend.Serve(backend.ServeOpts{
-		QueryDataHandler:   ds,
-		CheckHealthHandler: ds,
+		QueryDataHandler:    ds,
+		CheckHealthHandler:  ds,
+		CallResourceHandler: ds,
 	})
 	if err != nil {
 		backend.Logger.Error(err.Error())
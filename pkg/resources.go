@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+const (
+	defaultResourceLimit = 1000
+	maxResourceLimit     = 10000
+)
+
+func (a *AKIPSDatasource) newResourceHandler() backend.CallResourceHandler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/devices", a.handleDevices)
+	mux.HandleFunc("/children", a.handleChildren)
+	mux.HandleFunc("/attributes", a.handleAttributes)
+	mux.HandleFunc("/groups", a.handleGroups)
+	return httpadapter.New(mux)
+}
+
+// CallResource handles the discovery API used by selectors and template variables
+func (a *AKIPSDatasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return a.resourceHandler.CallResource(ctx, req, sender)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		backend.Logger.Error("Failed to write resource response", "error", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// listOptions are the common server side filtering parameters
+type listOptions struct {
+	filter *regexp.Regexp
+	limit  int
+}
+
+func parseListOptions(r *http.Request) (*listOptions, error) {
+	opt := listOptions{limit: defaultResourceLimit}
+	q := r.URL.Query()
+
+	if f := q.Get("filter"); f != "" {
+		re, err := regexp.Compile(f)
+		if err != nil {
+			return nil, err
+		}
+		opt.filter = re
+	}
+
+	if l := q.Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid limit: %q", l)
+		}
+		opt.limit = v
+	}
+	if opt.limit > maxResourceLimit {
+		opt.limit = maxResourceLimit
+	}
+
+	return &opt, nil
+}
+
+// list runs the command and writes unique names selected by the name function
+func (a *AKIPSDatasource) list(w http.ResponseWriter, r *http.Request, cmd akips.Command, name func(*akips.GenericResponseEntry) string) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	opt, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	pc := httpadapter.PluginConfigFromContext(r.Context())
+	inst, err := a.getInstance(&pc)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var res akips.GenericResponse
+	if err := inst.client.ExecCommand(r.Context(), cmd, &res); err != nil {
+		err = inst.client.Config().RedactError(toUserError(err))
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	names := make([]string, 0)
+	seen := make(map[string]struct{}, len(res))
+	for _, e := range res {
+		n := name(e)
+		if n == "" {
+			continue
+		}
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		if opt.filter != nil && !opt.filter.MatchString(n) {
+			continue
+		}
+		names = append(names, n)
+		if len(names) == opt.limit {
+			break
+		}
+	}
+
+	writeJSON(w, http.StatusOK, names)
+}
+
+func requireParam(w http.ResponseWriter, r *http.Request, names ...string) bool {
+	for _, n := range names {
+		if r.URL.Query().Get(n) == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": n + " is required"})
+			return false
+		}
+	}
+	return true
+}
+
+func (a *AKIPSDatasource) handleDevices(w http.ResponseWriter, r *http.Request) {
+	cmd := akips.MlistCommand{
+		Selector: akips.Selector{Type: "device"},
+		Level:    akips.LevelParent,
+	}
+	a.list(w, r, &cmd, func(e *akips.GenericResponseEntry) string { return e.Parent })
+}
+
+func (a *AKIPSDatasource) handleChildren(w http.ResponseWriter, r *http.Request) {
+	if !requireParam(w, r, "device") {
+		return
+	}
+	cmd := akips.MlistCommand{
+		Selector: akips.Selector{Parent: akips.Exact(r.URL.Query().Get("device"))},
+		Level:    akips.LevelChild,
+	}
+	a.list(w, r, &cmd, func(e *akips.GenericResponseEntry) string { return e.Child })
+}
+
+func (a *AKIPSDatasource) handleAttributes(w http.ResponseWriter, r *http.Request) {
+	if !requireParam(w, r, "device", "child") {
+		return
+	}
+	q := r.URL.Query()
+	cmd := akips.MlistCommand{
+		Selector: akips.Selector{
+			Parent: akips.Exact(q.Get("device")),
+			Child:  akips.Exact(q.Get("child")),
+		},
+		Level: akips.LevelAttribute,
+	}
+	a.list(w, r, &cmd, func(e *akips.GenericResponseEntry) string { return e.Attribute })
+}
+
+// rawCommand is a fixed command without parameters
+type rawCommand string
+
+func (c rawCommand) Build() (string, error) { return string(c), nil }
+
+func (a *AKIPSDatasource) handleGroups(w http.ResponseWriter, r *http.Request) {
+	a.list(w, r, rawCommand("list device group"), func(e *akips.GenericResponseEntry) string { return e.Parent })
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const (
+	defaultMaxConcurrentQueries = 4
+	defaultTimeout              = 60
+	defaultDialTimeout          = 10
+	defaultTLSHandshakeTimeout  = 10
+	defaultHTTPMethod           = "POST"
+	defaultCacheTTL             = 60
+	defaultCacheMaxSize         = 64
+	defaultChunkSize            = 7 * 24
+	defaultMaxConcurrentChunks  = 2
+)
+
+// datasourceSettings is the datasource's jsonData
+type datasourceSettings struct {
+	MaxConcurrentQueries int `json:"maxConcurrentQueries"`
+
+	// HTTPMethod is either POST (commands and the password are sent in a form encoded body) or GET
+	HTTPMethod string `json:"httpMethod"`
+
+	// Timeouts in seconds
+	Timeout             int `json:"timeout"`
+	DialTimeout         int `json:"dialTimeout"`
+	TLSHandshakeTimeout int `json:"tlsHandshakeTimeout"`
+	IdleConnTimeout     int `json:"idleConnTimeout"`
+
+	MaxIdleConns        int    `json:"maxIdleConns"`
+	MaxIdleConnsPerHost int    `json:"maxIdleConnsPerHost"`
+	ProxyURL            string `json:"proxyUrl"`
+
+	TLSAuth           bool   `json:"tlsAuth"`
+	TLSAuthWithCACert bool   `json:"tlsAuthWithCACert"`
+	TLSSkipVerify     bool   `json:"tlsSkipVerify"`
+	TLSServerName     string `json:"serverName"`
+	// TLSPinnedCerts are SHA-256 fingerprints of accepted server certificates, hex encoded
+	// and separated by commas or spaces. Colons within a fingerprint are ignored
+	TLSPinnedCerts string `json:"tlsPinnedCerts"`
+
+	// DisableCache turns off the response cache
+	DisableCache bool `json:"disableCache"`
+	// CacheTTL is in seconds
+	CacheTTL int `json:"cacheTTL"`
+	// CacheMaxSize is the total size of cached responses in MiB
+	CacheMaxSize int `json:"cacheMaxSize"`
+
+	// DisableChunking turns off splitting of long time ranges
+	DisableChunking bool `json:"disableChunking"`
+	// ChunkSize is the longest time range in hours queried at once
+	ChunkSize           int `json:"chunkSize"`
+	MaxConcurrentChunks int `json:"maxConcurrentChunks"`
+
+	// Secure fields
+	TLSCACert     string `json:"-"`
+	TLSClientCert string `json:"-"`
+	TLSClientKey  string `json:"-"`
+}
+
+func loadSettings(is *backend.DataSourceInstanceSettings) (*datasourceSettings, error) {
+	var s datasourceSettings
+	if len(is.JSONData) != 0 {
+		if err := json.Unmarshal(is.JSONData, &s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.MaxConcurrentQueries <= 0 {
+		s.MaxConcurrentQueries = defaultMaxConcurrentQueries
+	}
+	switch s.HTTPMethod = strings.ToUpper(s.HTTPMethod); s.HTTPMethod {
+	case "GET", "POST":
+	case "":
+		s.HTTPMethod = defaultHTTPMethod
+	default:
+		return nil, fmt.Errorf("unsupported HTTP method: %s", s.HTTPMethod)
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = defaultTimeout
+	}
+	if s.DialTimeout <= 0 {
+		s.DialTimeout = defaultDialTimeout
+	}
+	if s.TLSHandshakeTimeout <= 0 {
+		s.TLSHandshakeTimeout = defaultTLSHandshakeTimeout
+	}
+
+	if s.CacheTTL <= 0 {
+		s.CacheTTL = defaultCacheTTL
+	}
+	if s.CacheMaxSize <= 0 {
+		s.CacheMaxSize = defaultCacheMaxSize
+	}
+
+	if s.ChunkSize <= 0 {
+		s.ChunkSize = defaultChunkSize
+	}
+	if s.MaxConcurrentChunks <= 0 {
+		s.MaxConcurrentChunks = defaultMaxConcurrentChunks
+	}
+
+	s.TLSCACert = is.DecryptedSecureJSONData["tlsCACert"]
+	s.TLSClientCert = is.DecryptedSecureJSONData["tlsClientCert"]
+	s.TLSClientKey = is.DecryptedSecureJSONData["tlsClientKey"]
+
+	return &s, nil
+}
+
+// transport returns a new HTTP transport configured according to the settings
+func (s *datasourceSettings) transport() (*http.Transport, error) {
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{
+		Timeout:   time.Duration(s.DialTimeout) * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	t.TLSHandshakeTimeout = time.Duration(s.TLSHandshakeTimeout) * time.Second
+
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	if s.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = time.Duration(s.IdleConnTimeout) * time.Second
+	}
+	if s.MaxIdleConns > 0 {
+		t.MaxIdleConns = s.MaxIdleConns
+	}
+	if s.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	}
+	if s.ProxyURL != "" {
+		u, err := url.Parse(s.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %v", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	return t, nil
+}
+
+// tlsConfig returns the TLS configuration for the AKiPS connection or nil if defaults are sufficient
+func (s *datasourceSettings) tlsConfig() (*tls.Config, error) {
+	if !s.TLSAuth && !s.TLSAuthWithCACert && !s.TLSSkipVerify && s.TLSServerName == "" && s.TLSPinnedCerts == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		InsecureSkipVerify: s.TLSSkipVerify,
+		ServerName:         s.TLSServerName,
+	}
+
+	if s.TLSAuthWithCACert && s.TLSCACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(s.TLSCACert)) {
+			return nil, errors.New("failed to parse TLS CA certificate")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if s.TLSAuth {
+		cert, err := tls.X509KeyPair([]byte(s.TLSClientCert), []byte(s.TLSClientKey))
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if s.TLSPinnedCerts != "" {
+		pins, err := parseFingerprints(s.TLSPinnedCerts)
+		if err != nil {
+			return nil, err
+		}
+		cfg.VerifyPeerCertificate = verifyPinned(pins)
+	}
+
+	return cfg, nil
+}
+
+func parseFingerprints(s string) (map[[sha256.Size]byte]bool, error) {
+	pins := make(map[[sha256.Size]byte]bool)
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		b, err := hex.DecodeString(strings.Replace(f, ":", "", -1))
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 certificate fingerprint: %q", f)
+		}
+		var fp [sha256.Size]byte
+		copy(fp[:], b)
+		pins[fp] = true
+	}
+	return pins, nil
+}
+
+// verifyPinned accepts the connection only if the server certificate is one of the pinned ones.
+// It's called after the usual verification, if it isn't skipped, so a pinned self-signed certificate
+// may be used along with "Skip TLS verify"
+func verifyPinned(pins map[[sha256.Size]byte]bool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) != 0 && pins[sha256.Sum256(rawCerts[0])] {
+			return nil
+		}
+		return errors.New("the server certificate doesn't match any pinned fingerprint")
+	}
+}
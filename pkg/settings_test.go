@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTLSPinnedCerts(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	sum := sha256.Sum256(srv.Certificate().Raw)
+	fp := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var colons []string
+	for i := 0; i < len(fp); i += 2 {
+		colons = append(colons, fp[i:i+2])
+	}
+	other := strings.Repeat("00", sha256.Size)
+
+	for _, tc := range []struct {
+		pins string
+		ok   bool
+	}{
+		{pins: fp, ok: true},
+		// openssl output format along with another pin
+		{pins: other + ", " + strings.Join(colons, ":"), ok: true},
+		{pins: other, ok: false},
+	} {
+		// The test server certificate is self-signed
+		s := &datasourceSettings{TLSSkipVerify: true, TLSPinnedCerts: tc.pins}
+		tr, err := s.transport()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: unexpected result %v", tc.pins, err)
+		}
+		tr.CloseIdleConnections()
+	}
+
+	for _, pins := range []string{"xyz", "abcd", fp + "00"} {
+		if _, err := (&datasourceSettings{TLSPinnedCerts: pins}).tlsConfig(); err == nil {
+			t.Errorf("%s: expected an error", pins)
+		}
+	}
+}
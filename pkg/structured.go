@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/reddercode/akips-grafana/pkg/akips"
+)
+
+const queryModeStructured = "structured"
+
+// Structured commands
+const (
+	commandSeries  = "series"
+	commandCseries = "cseries"
+	commandMget    = "mget"
+	commandMlist   = "mlist"
+	commandGet     = "get"
+)
+
+type patternModel struct {
+	Value string `json:"value"`
+	Regex bool   `json:"regex"`
+}
+
+// structuredModel describes a query without AKiPS syntax
+type structuredModel struct {
+	Command     string       `json:"command"`
+	Aggregation string       `json:"aggregation"`
+	Type        string       `json:"type"`
+	Device      patternModel `json:"device"`
+	Child       patternModel `json:"child"`
+	Attribute   patternModel `json:"attribute"`
+	// Interval overrides the dashboard interval, in seconds
+	Interval int64 `json:"interval"`
+	// Time overrides the dashboard time range using the AKiPS syntax, e.g. `last1h`
+	Time string `json:"time"`
+}
+
+func (q *query) isStructured() bool {
+	return q.model.Mode == queryModeStructured
+}
+
+// pattern falls back to the selector value if the pattern is empty
+func pattern(p *patternModel, selected string) (akips.Pattern, error) {
+	if p.Value == "" {
+		if selected == "" {
+			return akips.Any(), nil
+		}
+		return akips.Exact(selected), nil
+	}
+	if p.Regex {
+		if _, err := regexp.Compile(p.Value); err != nil {
+			return akips.Pattern{}, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		return akips.Regex(p.Value), nil
+	}
+	return akips.Exact(p.Value), nil
+}
+
+func (q *query) selector() (sel akips.Selector, err error) {
+	s := q.model.Structured
+	sel.Type = s.Type
+	if sel.Parent, err = pattern(&s.Device, q.model.Device); err != nil {
+		return sel, fmt.Errorf("device: %v", err)
+	}
+	if sel.Child, err = pattern(&s.Child, q.model.Child); err != nil {
+		return sel, fmt.Errorf("child: %v", err)
+	}
+	if sel.Attribute, err = pattern(&s.Attribute, q.model.Attribute); err != nil {
+		return sel, fmt.Errorf("attribute: %v", err)
+	}
+	return sel, nil
+}
+
+// buildCommand validates the structured model and compiles it into an AKiPS command
+func (q *query) buildCommand() (string, error) {
+	s := q.model.Structured
+	if s == nil {
+		return "", errors.New("structured query is empty")
+	}
+
+	sel, err := q.selector()
+	if err != nil {
+		return "", err
+	}
+
+	var cmd akips.Command
+	switch s.Command {
+	case commandSeries, commandCseries:
+		interval := s.Interval
+		if interval == 0 {
+			interval = q.interval()
+		}
+
+		tw := akips.TimeWindow{Spec: s.Time}
+		if s.Time == "" {
+			tw.From = q.query.TimeRange.From
+			tw.To = q.query.TimeRange.To
+		}
+
+		cmd = &akips.SeriesCommand{
+			Aggregation: s.Aggregation,
+			Interval:    interval,
+			Time:        tw,
+			Selector:    sel,
+			CSV:         s.Command == commandCseries,
+		}
+
+	case commandMget:
+		cmd = &akips.MgetCommand{Selector: sel}
+
+	case commandMlist:
+		cmd = &akips.MlistCommand{Selector: sel, Level: akips.LevelAttribute}
+
+	case commandGet:
+		if sel.Parent.Regex || sel.Child.Regex || sel.Attribute.Regex {
+			return "", errors.New("get doesn't accept regular expressions")
+		}
+		cmd = &akips.GetCommand{
+			Parent:    sel.Parent.Value,
+			Child:     sel.Child.Value,
+			Attribute: sel.Attribute.Value,
+		}
+
+	default:
+		return "", fmt.Errorf("unsupported command: %q", s.Command)
+	}
+
+	return cmd.Build()
+}
+
+// interval returns the dashboard interval in seconds rounded up to a multiple of 60 sec
+func (q *query) interval() int64 {
+	return int64(((q.query.Interval + minInterval - 1) / minInterval) * minInterval / time.Second)
+}
@@ -1,6 +1,49 @@
-import { DataQueryRequest, ScopedVars, MetricFindValue, toDataFrame, DataSourceInstanceSettings } from '@grafana/data';
+import {
+  AnnotationEvent,
+  AnnotationQueryRequest,
+  DataQueryRequest,
+  ScopedVars,
+  MetricFindValue,
+  toDataFrame,
+  DataSourceInstanceSettings,
+} from '@grafana/data';
 import { DataSourceWithBackend, getTemplateSrv } from '@grafana/runtime';
-import { Query } from './types';
+import { AKIPSAnnotationQuery, PatternQuery, Query } from './types';
+
+// Separates the values of a multi-value variable, it can't occur in names
+const VALUE_SEPARATOR = '\u0000';
+
+// splitArgs splits function arguments on commas outside of double quotes. The quotes are removed
+// and a backslash escapes the next character within them, so names containing commas can be given literally
+export function splitArgs(s: string): string[] {
+  if (!s.trim()) {
+    return [];
+  }
+  const args: string[] = [];
+  let arg = '';
+  let quoted = false;
+  for (let i = 0; i < s.length; i++) {
+    const c = s[i];
+    if (quoted) {
+      if (c === '\\' && i + 1 < s.length) {
+        arg += s[++i];
+      } else if (c === '"') {
+        quoted = false;
+      } else {
+        arg += c;
+      }
+    } else if (c === '"') {
+      quoted = true;
+    } else if (c === ',') {
+      args.push(arg.trim());
+      arg = '';
+    } else {
+      arg += c;
+    }
+  }
+  args.push(arg.trim());
+  return args;
+}
 
 export class DataSource extends DataSourceWithBackend<Query> {
   static DEFAULT_QUERY =
@@ -9,6 +52,10 @@ export class DataSource extends DataSourceWithBackend<Query> {
   private templateSrv = getTemplateSrv();
 
   static shouldUpdate(q: Query): boolean {
+    if (q.mode === 'structured') {
+      return !!(q.structured && q.structured.command);
+    }
+
     const hasDeviceVar = /\${?__device}?/.test(q.query || '');
     const hasChildVar = /\${?__child}?/.test(q.query || '');
     const hasAttributeVar = /\${?__attribute}?/.test(q.query || '');
@@ -29,16 +76,45 @@ export class DataSource extends DataSourceWithBackend<Query> {
    * Convert a query to a simple text string
    */
   getQueryDisplayText(query: Query): string {
+    if (query.mode === 'structured' && query.structured) {
+      const { command, aggregation, device, child, attribute } = query.structured;
+      return [command, aggregation, device?.value, child?.value, attribute?.value].filter((v) => !!v).join(' ');
+    }
     return query.query || '';
   }
 
-  // Variable query action.
+  // Discovery API served by the backend
+  listDevices(filter?: string): Promise<string[]> {
+    return this.getResource('devices', { filter });
+  }
+
+  listChildren(device: string, filter?: string): Promise<string[]> {
+    return this.getResource('children', { device, filter });
+  }
+
+  listAttributes(device: string, child: string, filter?: string): Promise<string[]> {
+    return this.getResource('attributes', { device, child, filter });
+  }
+
+  listGroups(filter?: string): Promise<string[]> {
+    return this.getResource('groups', { filter });
+  }
+
+  // Variable query action. Discovery functions are served by the resource API:
+  // devices([filter]), children(device[, filter]), attributes(device, child[, filter]) and groups([filter]).
+  // Any other query is executed as an AKiPS command and the names are taken from its output
   async metricFindQuery(request: string): Promise<MetricFindValue[]> {
+    const names = await this.findNames(request.trim());
+    if (names) {
+      return names.map((text) => ({ text }));
+    }
+
+    const query = this.templateSrv.replace(request, {}).trim();
     const targets: Query[] = [
       {
         refId: 'table',
         queryType: 'table',
-        query: this.templateSrv.replace(request, {}),
+        query,
         omitParents: true,
       },
     ];
@@ -53,11 +129,115 @@ export class DataSource extends DataSourceWithBackend<Query> {
     return [];
   }
 
+  // findNames runs a discovery function. It returns undefined if the query isn't one.
+  // Arguments are split before the variables are replaced so values containing commas are kept whole.
+  // A multi-value variable in a name argument lists the names for each of its values
+  private async findNames(request: string): Promise<string[] | undefined> {
+    if (request === '') {
+      return this.listDevices();
+    }
+
+    const m = /^(devices|children|attributes|groups)\((.*)\)$/.exec(request);
+    if (!m) {
+      return undefined;
+    }
+    const args = splitArgs(m[2]);
+    const names = (i: number) => this.replaceNames(args[i] || '');
+    // Filters are regular expressions, the variable values are escaped
+    const filter = (i: number) => (args[i] ? this.templateSrv.replace(args[i], {}, 'regex') : undefined);
+
+    const lists: Array<Promise<string[]>> = [];
+    switch (m[1]) {
+      case 'devices':
+        return this.listDevices(filter(0));
+      case 'children':
+        for (const device of names(0)) {
+          lists.push(this.listChildren(device, filter(1)));
+        }
+        break;
+      case 'attributes':
+        for (const device of names(0)) {
+          for (const child of names(1)) {
+            lists.push(this.listAttributes(device, child, filter(2)));
+          }
+        }
+        break;
+      default:
+        return this.listGroups(filter(0));
+    }
+
+    const all = await Promise.all(lists);
+    return Array.from(new Set(([] as string[]).concat(...all)));
+  }
+
+  // replaceNames replaces the variables in a name argument returning a name per value of multi-value variables
+  private replaceNames(arg: string): string[] {
+    const s = this.templateSrv.replace(arg, {}, (value: string | string[]) =>
+      Array.isArray(value) ? value.join(VALUE_SEPARATOR) : value
+    );
+    return s.split(VALUE_SEPARATOR);
+  }
+
+  // Annotations are produced by the backend as frames with time, timeEnd, title, text and tags columns
+  async annotationQuery(options: AnnotationQueryRequest<AKIPSAnnotationQuery>): Promise<AnnotationEvent[]> {
+    const { annotation } = options;
+    if (!annotation.query) {
+      return [];
+    }
+
+    const targets: Query[] = [
+      {
+        refId: 'annotations',
+        queryType: 'annotations',
+        query: this.templateSrv.replace(annotation.query, {}),
+      },
+    ];
+
+    const response = await this.query({
+      targets,
+      range: options.range,
+      rangeRaw: options.rangeRaw,
+      interval: this.interval,
+      intervalMs: 60000,
+    } as DataQueryRequest<Query>).toPromise();
+
+    const events: AnnotationEvent[] = [];
+    for (const frame of response.data) {
+      const df = toDataFrame(frame);
+      const field = (name: string) => df.fields.find((f) => f.name === name);
+      const [time, timeEnd, title, text, tags] = ['time', 'timeEnd', 'title', 'text', 'tags'].map(field);
+      if (!time) {
+        continue;
+      }
+      for (let i = 0; i < df.length; i++) {
+        const tagsStr: string = tags ? tags.values.get(i) : '';
+        events.push({
+          annotation,
+          time: time.values.get(i),
+          timeEnd: timeEnd ? timeEnd.values.get(i) : undefined,
+          title: title ? title.values.get(i) : undefined,
+          text: text ? text.values.get(i) : undefined,
+          tags: tagsStr ? tagsStr.split(',') : [],
+        });
+      }
+    }
+    return events;
+  }
+
   // Called by DataSourceWithBackend::query
   applyTemplateVariables(query: Query, scopedVars?: ScopedVars): Query {
+    const replacePattern = (p?: PatternQuery): PatternQuery | undefined =>
+      p && { ...p, value: this.templateSrv.replace(p.value, scopedVars, p.regex ? 'regex' : undefined) };
+
     return {
       ...query,
       query: this.templateSrv.replace(query.query, scopedVars),
+      structured: query.structured && {
+        ...query.structured,
+        device: replacePattern(query.structured.device),
+        child: replacePattern(query.structured.child),
+        attribute: replacePattern(query.structured.attribute),
+      },
     };
   }
 
@@ -5,7 +5,12 @@ import { AKIPSQueryField } from './query_field';
 import { ConfigEditor } from './config_editor';
 import { Query } from './types';
 
+class AKIPSAnnotationsQueryCtrl {
+  static templateUrl = 'partials/annotations.editor.html';
+}
+
 export const plugin = new DataSourcePlugin<DataSource, Query>(DataSource)
   .setConfigEditor(ConfigEditor)
   .setQueryEditor(AKIPSQueryEditor)
-  .setExploreQueryField(AKIPSQueryField);
+  .setExploreQueryField(AKIPSQueryField)
+  .setAnnotationQueryCtrl(AKIPSAnnotationsQueryCtrl);
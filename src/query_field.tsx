@@ -1,13 +1,43 @@
 import { ExploreQueryFieldProps, SelectableValue } from '@grafana/data';
-import { QueryField, SlatePrism, Select } from '@grafana/ui';
+import { Input, QueryField, SlatePrism, Select } from '@grafana/ui';
 import React from 'react';
 import Slate from 'slate';
 import Prism from 'prismjs';
 import { DataSource } from './datasource';
-import { Query, QueryType } from './types';
+import {
+  ColumnType,
+  PatternQuery,
+  Query,
+  QueryMode,
+  QueryType,
+  StructuredCommand,
+  StructuredQuery,
+} from './types';
 import syntax from './syntax';
 import {} from '@emotion/core'; // https://github.com/grafana/grafana/issues/26512
 
+const COLUMN_TYPES: ColumnType[] = ['int', 'float', 'bool', 'time', 'string'];
+
+// formatColumnTypes and parseColumnTypes convert column types to and from `Value #0=int, Value #1=time`
+function formatColumnTypes(types: { [column: string]: ColumnType } = {}): string {
+  return Object.keys(types)
+    .map((column) => `${column}=${types[column]}`)
+    .join(', ');
+}
+
+function parseColumnTypes(s: string): { [column: string]: ColumnType } | undefined {
+  const types: { [column: string]: ColumnType } = {};
+  for (const item of s.split(',')) {
+    const i = item.lastIndexOf('=');
+    const column = item.slice(0, i).trim();
+    const type = item.slice(i + 1).trim() as ColumnType;
+    if (i > 0 && column && COLUMN_TYPES.indexOf(type) >= 0) {
+      types[column] = type;
+    }
+  }
+  return Object.keys(types).length ? types : undefined;
+}
+
 type AKIPSQueryFieldProps = ExploreQueryFieldProps<DataSource, Query>;
 
 interface AKIPSQueryFieldState {
@@ -19,9 +49,46 @@ interface AKIPSQueryFieldState {
 const QUERY_TYPES: Array<SelectableValue<QueryType>> = [
   { label: 'Time series', value: 'time_series' },
   { label: 'Table', value: 'table' },
+  { label: 'Attributes', value: 'attributes' },
   { label: 'CSV', value: 'csv' },
+  { label: 'Netflow', value: 'netflow' },
+  { label: 'Netflow time series', value: 'netflow_time_series' },
+  { label: 'Messages', value: 'messages' },
+];
+
+const QUERY_MODES: Array<SelectableValue<QueryMode>> = [
+  { label: 'Text', value: 'text' },
+  { label: 'Builder', value: 'structured' },
+];
+
+const COMMANDS: Array<SelectableValue<StructuredCommand>> = [
+  { label: 'series', value: 'series' },
+  { label: 'cseries', value: 'cseries' },
+  { label: 'mget', value: 'mget' },
+  { label: 'mlist', value: 'mlist' },
+  { label: 'get', value: 'get' },
+];
+
+const AGGREGATIONS: Array<SelectableValue<string>> = [
+  { label: 'avg', value: 'avg' },
+  { label: 'total', value: 'total' },
+  { label: 'min', value: 'min' },
+  { label: 'max', value: 'max' },
 ];
 
+// Patterns are edited as plain strings, `/.../` denotes a regular expression
+function patternToString(p?: PatternQuery): string {
+  if (!p || !p.value) {
+    return '';
+  }
+  return p.regex ? `/${p.value}/` : p.value;
+}
+
+function stringToPattern(s: string): PatternQuery {
+  const m = /^\/(.*)\/$/.exec(s);
+  return m ? { value: m[1], regex: true } : { value: s };
+}
+
 export class AKIPSQueryField extends React.PureComponent<AKIPSQueryFieldProps, AKIPSQueryFieldState> {
   plugins: Slate.Plugin[];
 
@@ -58,9 +125,9 @@ export class AKIPSQueryField extends React.PureComponent<AKIPSQueryFieldProps, A
 
   private async updateDevices() {
     const { datasource } = this.props;
-    const result = (await datasource.metricFindQuery('mlist device *')).map<SelectableValue<string>>((value) => ({
-      label: value.text,
-      value: value.text,
+    const result = (await datasource.listDevices()).map<SelectableValue<string>>((value) => ({
+      label: value,
+      value,
     }));
 
     this.setState({
@@ -70,9 +137,9 @@ export class AKIPSQueryField extends React.PureComponent<AKIPSQueryFieldProps, A
 
   private async updateChildren(dev: string) {
     const { datasource } = this.props;
-    const result = (await datasource.metricFindQuery(`mlist * "${dev}" *`)).map<SelectableValue<string>>((value) => ({
-      label: value.text,
-      value: value.text,
+    const result = (await datasource.listChildren(dev)).map<SelectableValue<string>>((value) => ({
+      label: value,
+      value,
     }));
 
     this.setState({
@@ -82,12 +149,10 @@ export class AKIPSQueryField extends React.PureComponent<AKIPSQueryFieldProps, A
 
   private async updateAttributes(dev: string, child: string) {
     const { datasource } = this.props;
-    const result = (await datasource.metricFindQuery(`mlist * "${dev}" "${child}" *`)).map<SelectableValue<string>>(
-      (value) => ({
-        label: value.text,
-        value: value.text,
-      })
-    );
+    const result = (await datasource.listAttributes(dev, child)).map<SelectableValue<string>>((value) => ({
+      label: value,
+      value,
+    }));
 
     this.setState({
       attributes: result,
@@ -105,6 +170,107 @@ export class AKIPSQueryField extends React.PureComponent<AKIPSQueryFieldProps, A
     }
   }
 
+  private changeStructured(values: Partial<StructuredQuery>, override?: boolean) {
+    const { query } = this.props;
+    this.changeQuery({ structured: { ...query.structured, ...values } }, override);
+  }
+
+  private patternInput(key: 'device' | 'child' | 'attribute', label: string) {
+    const structured = this.props.query.structured || {};
+    return (
+      <div className="gf-form gf-form--grow">
+        <label className="gf-form-label">{label}</label>
+        <Input
+          type="text"
+          value={patternToString(structured[key])}
+          placeholder="selected value or /regex/"
+          onChange={(event: React.ChangeEvent<HTMLInputElement>) =>
+            this.changeStructured({ [key]: stringToPattern(event.currentTarget.value) })
+          }
+          onBlur={this.props.onRunQuery}
+        />
+      </div>
+    );
+  }
+
+  private renderBuilder() {
+    const structured = this.props.query.structured || {};
+    const isSeries = structured.command === 'series' || structured.command === 'cseries';
+    return (
+      <>
+        <div className="gf-form-inline">
+          <div className="gf-form">
+            <label className="gf-form-label">Command</label>
+            <Select<StructuredCommand>
+              isSearchable={false}
+              options={COMMANDS}
+              onChange={(option) => this.changeStructured({ command: option.value }, true)}
+              value={COMMANDS.find((option) => option.value === structured.command)}
+              placeholder="Select a command"
+            />
+          </div>
+          {isSeries && (
+            <div className="gf-form">
+              <label className="gf-form-label">Aggregation</label>
+              <Select<string>
+                isSearchable={false}
+                options={AGGREGATIONS}
+                onChange={(option) => this.changeStructured({ aggregation: option.value }, true)}
+                value={AGGREGATIONS.find((option) => option.value === structured.aggregation)}
+              />
+            </div>
+          )}
+          <div className="gf-form">
+            <label className="gf-form-label">Type</label>
+            <Input
+              type="text"
+              value={structured.type || ''}
+              placeholder="*"
+              onChange={(event: React.ChangeEvent<HTMLInputElement>) =>
+                this.changeStructured({ type: event.currentTarget.value })
+              }
+              onBlur={this.props.onRunQuery}
+            />
+          </div>
+          {isSeries && (
+            <div className="gf-form">
+              <label className="gf-form-label">Interval</label>
+              <Input
+                type="number"
+                step={60}
+                value={structured.interval || ''}
+                placeholder="auto"
+                onChange={(event: React.ChangeEvent<HTMLInputElement>) =>
+                  this.changeStructured({ interval: parseInt(event.currentTarget.value, 10) || undefined })
+                }
+                onBlur={this.props.onRunQuery}
+              />
+            </div>
+          )}
+          {isSeries && (
+            <div className="gf-form">
+              <label className="gf-form-label">Time</label>
+              <Input
+                type="text"
+                value={structured.time || ''}
+                placeholder="dashboard"
+                onChange={(event: React.ChangeEvent<HTMLInputElement>) =>
+                  this.changeStructured({ time: event.currentTarget.value })
+                }
+                onBlur={this.props.onRunQuery}
+              />
+            </div>
+          )}
+        </div>
+        <div className="gf-form-inline">
+          {this.patternInput('device', 'Device pattern')}
+          {this.patternInput('child', 'Child pattern')}
+          {this.patternInput('attribute', 'Attribute pattern')}
+        </div>
+      </>
+    );
+  }
+
   private onChangeDevice = (option: SelectableValue<string> | null) => {
     this.setState(
       {
@@ -197,22 +363,35 @@ export class AKIPSQueryField extends React.PureComponent<AKIPSQueryFieldProps, A
             />
           </div>
         </div>
+        {query.mode === 'structured' ? (
+          this.renderBuilder()
+        ) : (
+          <div className="gf-form-inline">
+            <div className="gf-form gf-form--grow flex-shrink-1">
+              <label className="gf-form-label">Query</label>
+              <QueryField
+                query={query.query}
+                additionalPlugins={this.plugins}
+                onChange={(value) => this.changeQuery({ query: value })}
+                onRunQuery={this.props.onRunQuery}
+                onBlur={this.props.onBlur}
+                placeholder="Enter an AKiPS query"
+                portalOrigin="akips"
+                syntaxLoaded
+              />
+            </div>
+          </div>
+        )}
         <div className="gf-form-inline">
-          <div className="gf-form gf-form--grow flex-shrink-1">
-            <label className="gf-form-label">Query</label>
-            <QueryField
-              query={query.query}
-              additionalPlugins={this.plugins}
-              onChange={(value) => this.changeQuery({ query: value })}
-              onRunQuery={this.props.onRunQuery}
-              onBlur={this.props.onBlur}
-              placeholder="Enter an AKiPS query"
-              portalOrigin="akips"
-              syntaxLoaded
+          <div className="gf-form">
+            <label className="gf-form-label">Mode</label>
+            <Select<QueryMode>
+              isSearchable={false}
+              options={QUERY_MODES}
+              onChange={(option) => this.changeQuery({ mode: option.value }, true)}
+              value={QUERY_MODES.find((option) => option.value === query.mode) || QUERY_MODES[0]}
             />
           </div>
-        </div>
-        <div className="gf-form-inline">
           <div className="gf-form">
             <label className="gf-form-label">Format</label>
             <Select<QueryType>
@@ -222,6 +401,20 @@ export class AKIPSQueryField extends React.PureComponent<AKIPSQueryFieldProps, A
               value={this.queryType()}
             />
           </div>
+          {(query.queryType === 'table' || query.queryType === 'csv') && (
+            <div className="gf-form gf-form--grow">
+              <label className="gf-form-label">Column types</label>
+              <Input
+                type="text"
+                key={`_column_types_key_${query.queryType}`}
+                defaultValue={formatColumnTypes(query.columnTypes)}
+                placeholder="Value #0=int, Value #1=time"
+                onBlur={(event: React.FocusEvent<HTMLInputElement>) =>
+                  this.changeQuery({ columnTypes: parseColumnTypes(event.currentTarget.value) }, true)
+                }
+              />
+            </div>
+          )}
         </div>
       </>
     );
@@ -1,6 +1,36 @@
-import { DataQuery } from '@grafana/data';
+import { DataQuery, DataSourceJsonData } from '@grafana/data';
 
-export type QueryType = 'table' | 'time_series' | 'csv';
+export type QueryType =
+  | 'table'
+  | 'attributes'
+  | 'time_series'
+  | 'csv'
+  | 'netflow'
+  | 'netflow_time_series'
+  | 'messages'
+  | 'annotations';
+
+export type QueryMode = 'text' | 'structured';
+
+export type ColumnType = 'int' | 'float' | 'bool' | 'time' | 'string';
+
+export type StructuredCommand = 'series' | 'cseries' | 'mget' | 'mlist' | 'get';
+
+export interface PatternQuery {
+  value?: string;
+  regex?: boolean;
+}
+
+export interface StructuredQuery {
+  command?: StructuredCommand;
+  aggregation?: string;
+  type?: string;
+  device?: PatternQuery;
+  child?: PatternQuery;
+  attribute?: PatternQuery;
+  interval?: number;
+  time?: string;
+}
 
 export interface Query extends DataQuery {
   queryType?: QueryType;
@@ -9,8 +39,45 @@ export interface Query extends DataQuery {
   child?: string;
   attribute?: string;
   omitParents?: boolean;
+  mode?: QueryMode;
+  structured?: StructuredQuery;
+  columnTypes?: { [column: string]: ColumnType };
+}
+
+export interface AKIPSAnnotationQuery {
+  query?: string;
+}
+
+export interface AKIPSJSONData extends DataSourceJsonData {
+  maxConcurrentQueries?: number;
+  disableCache?: boolean;
+  cacheTTL?: number;
+  cacheMaxSize?: number;
+  disableChunking?: boolean;
+  chunkSize?: number;
+  maxConcurrentChunks?: number;
+  httpMethod?: string;
+  httpHeaderName1?: string;
+  timeout?: number;
+  dialTimeout?: number;
+  tlsHandshakeTimeout?: number;
+  idleConnTimeout?: number;
+  maxIdleConns?: number;
+  maxIdleConnsPerHost?: number;
+  proxyUrl?: string;
+  tlsAuth?: boolean;
+  tlsAuthWithCACert?: boolean;
+  tlsSkipVerify?: boolean;
+  serverName?: string;
+  tlsPinnedCerts?: string;
 }
 
 export interface AKIPSSecureJSONData {
   password?: string;
+  basicAuthPassword?: string;
+  bearerToken?: string;
+  httpHeaderValue1?: string;
+  tlsCACert?: string;
+  tlsClientCert?: string;
+  tlsClientKey?: string;
 }